@@ -2,72 +2,232 @@ package blockchain
 
 import (
 	"crypto/rsa"
-	"database/sql"
-	"os"
+	"encoding/json"
+	"errors"
+	"maps"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type BlockChain struct {
-	DB    *sql.DB
-	index uint64
+	store  Store
+	index  uint64 // index of the next block, the number of blocks stored
+	tip    *Block // cached last block, nil until loaded
+	mu     sync.Mutex
+	config atomic.Pointer[GenesisConfig] // of the genesis block, nil until it is stored
 }
 
+// JSON names of Transaction and Block are kept short, they are repeated for every
+// transaction of every block sent or stored. See MarshalCanonical.
 type Transaction struct {
-	RandBytes []byte
-	PrevBlock []byte
-	Sender    string
-	Receiver  string
-	Value     uint64
-	ToStorage uint64
-	CurrHash  []byte
-	Signature []byte
+	RandBytes []byte `json:"rand"`
+	PrevBlock []byte `json:"prev"`
+	Sender    string `json:"from"`
+	Receiver  string `json:"to"`
+	Value     uint64 `json:"value"`
+	ToStorage uint64 `json:"fee"`
+	CurrHash  []byte `json:"hash"`
+	Signature []byte `json:"sig"`
+	PublicKey []byte `json:"key"` // PKCS#1 sender key, its address must equal Sender
 }
 
+// Block.Mapping holds the balance after the block of every account the block touches,
+// accounts it doesn't mention keep the balance from an earlier block.
 type Block struct {
-	CurrHash     []byte
-	PrevHash     []byte
-	Nonce        uint64
-	Difficulty   uint8
-	Miner        string
-	Signature    []byte
-	Timestamp    time.Time
-	Transactions []Transaction
-	Mapping      map[string]uint64
+	CurrHash     []byte            `json:"hash"`
+	PrevHash     []byte            `json:"prev"`
+	Nonce        uint64            `json:"nonce"`
+	Difficulty   uint8             `json:"diff"`
+	Miner        string            `json:"miner"`
+	Signature    []byte            `json:"sig"` // RSA-PSS signature by the miner over CurrHash, see User.SignBlock
+	MinerKey     []byte            `json:"key"` // PKCS#1 miner key, its address must equal Miner
+	Timestamp    time.Time         `json:"time"`
+	Transactions []Transaction     `json:"txs"`
+	Mapping      map[string]uint64 `json:"map"`
+	Genesis      *GenesisConfig    `json:"genesis,omitempty"` // set on the genesis block only
 }
 
+var (
+	ErrPrevHash       = errors.New("blockchain: block does not extend the chain tip")
+	ErrNotFound       = errors.New("blockchain: block not found")
+	ErrDeserialize    = errors.New("blockchain: can't deserialize block")
+	ErrBlockSignature = errors.New("blockchain: invalid miner signature")
+	ErrProofOfWork    = errors.New("blockchain: insufficient proof of work")
+	ErrMapping        = errors.New("blockchain: block Mapping doesn't match its transactions")
+)
+
 type User struct {
 	PrivateKey *rsa.PrivateKey
 }
 
 const (
-	CreateTable = `
-	create table block_chain (
-	    id 
-	)
-`
+	GenesisBlock  = "GENESIS-BLOCK" // CurrHash of genesis blocks made before they carried a Genesis config
+	StorageChain  = "STORAGE-CHAIN"
+	StorageValue  = 100
+	GenesisReward = 100
+	MiningReward  = GenesisReward // credited to the miner of every block after genesis
+	StorageReward = 1             // lowest storage fee of a transfer, see Fee
+	RandBytesSize = 32
 )
 
+var errSerialize = errors.New("blockchain: can't serialize block")
+
+// NewChain creates the sqlite file filename, see CreateSQLiteStore, holding the
+// genesis block that credits receiver.
 func NewChain(filename, receiver string) error {
-	file, err := os.Create(filename)
+	store, err := CreateSQLiteStore(filename)
 	if err != nil {
 		return err
 	}
-	file.Close()
-	db, err := sql.Open("sqlite3", filename)
+	defer store.Close()
+	_, err = NewChainWithStore(store, receiver)
+	return err
+}
+
+// NewMemoryChain is NewChain in a MemoryStore, for tests and ephemeral nodes.
+func NewMemoryChain(receiver string) (*BlockChain, error) {
+	return NewChainWithStore(NewMemoryStore(), receiver)
+}
+
+// NewChainWithStore adds the genesis block crediting receiver to the empty store.
+func NewChainWithStore(store Store, receiver string) (*BlockChain, error) {
+	return newChainWithConfig(store, DefaultGenesisConfig(receiver))
+}
+
+// LoadChain opens a chain created by NewChain.
+func LoadChain(filename string) (*BlockChain, error) {
+	store, err := OpenSQLiteStore(filename)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := OpenChain(store)
 	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return chain, nil
+}
+
+// OpenChain uses the blocks in store.
+func OpenChain(store Store) (*BlockChain, error) {
+	height, err := store.Height()
+	if err != nil {
+		return nil, err
+	}
+	chain := &BlockChain{store: store, index: height}
+	if height > 0 {
+		genesis, err := store.GetBlock(0)
+		if err != nil {
+			return nil, err
+		}
+		chain.setConfig(genesis)
+	}
+	return chain, nil
+}
+
+// Close the store of the chain.
+func (chain *BlockChain) Close() error {
+	return chain.store.Close()
+}
+
+// AddBlock stores block at the next index. Only the genesis block may have an empty
+// PrevHash, every later block must extend the current tip and pass the checks of
+// ResolveFork, see checkBlock.
+func (chain *BlockChain) AddBlock(block *Block) error {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	index := chain.index
+	if index == 0 {
+		if len(block.PrevHash) != 0 {
+			return ErrPrevHash
+		}
+	} else {
+		tip, err := chain.tipLocked()
+		if err != nil {
+			return err
+		}
+		if err := chain.checkBlock(block, tip, index-1, nil); err != nil {
+			return err
+		}
+	}
+	if err := chain.store.PutBlocks(index, []*Block{block}); err != nil {
 		return err
 	}
-	defer db.Close()
-	_, err = db.Exec(CreateTable)
-	chain := BlockChain{DB: db}
-	genesis := &Block{
-		CurrHash:  []byte(GenesisBlock),
-		Mapping:   make(map[string]uint64),
-		Miner:     receiver,
-		Timestamp: time.Now(),
+	if index == 0 {
+		chain.setConfig(block)
 	}
-	genesis.Mapping[StorageChain] = StorageValue
-	genesis.Mapping[receiver] = GenesisReward
-	chain.AddBlock(genesis)
+	chain.index = index + 1
+	chain.tip = cloneBlock(block)
 	return nil
 }
+
+// GetBlock loads the block stored at index.
+func (chain *BlockChain) GetBlock(index uint64) (*Block, error) {
+	return chain.store.GetBlock(index)
+}
+
+// LastBlock loads the chain tip, it is Tip.
+func (chain *BlockChain) LastBlock() (*Block, error) {
+	return chain.Tip()
+}
+
+// Tip returns the last block, cached after the first load and kept up to date by
+// AddBlock and ResolveFork.
+func (chain *BlockChain) Tip() (*Block, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	tip, err := chain.tipLocked()
+	if err != nil {
+		return nil, err
+	}
+	return cloneBlock(tip), nil
+}
+
+func (chain *BlockChain) tipLocked() (*Block, error) {
+	if chain.tip == nil {
+		if chain.index == 0 {
+			return nil, ErrNotFound
+		}
+		block, err := chain.store.GetBlock(chain.index - 1)
+		if err != nil {
+			return nil, err
+		}
+		chain.tip = block
+	}
+	return chain.tip, nil
+}
+
+// Height is the number of blocks, the index of the next block.
+func (chain *BlockChain) Height() (uint64, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	return chain.index, nil
+}
+
+// cloneBlock copies block so callers of Tip can't change the cached one through
+// its fields. Transactions are shared, they aren't modified once a block is stored.
+func cloneBlock(block *Block) *Block {
+	clone := *block
+	clone.Mapping = maps.Clone(block.Mapping)
+	return &clone
+}
+
+// SerializeBlock is MarshalCanonical as a string, empty when block can't be encoded.
+func SerializeBlock(block *Block) string {
+	jsonData, err := block.MarshalCanonical()
+	if err != nil {
+		return ""
+	}
+	return string(jsonData)
+}
+
+// DeserializeBlock decodes a block in the current or the untagged legacy format.
+func DeserializeBlock(data string) *Block {
+	var block Block
+	err := json.Unmarshal([]byte(data), &block)
+	if err != nil {
+		return nil
+	}
+	return &block
+}
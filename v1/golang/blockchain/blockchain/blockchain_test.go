@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAddBlock(t *testing.T) {
+	miner := testUsers()[0]
+	chain := newTestChain(t, miner.Address())
+	first := mine(t, chain, miner)
+	second := mine(t, chain, miner)
+	if height, _ := chain.Height(); height != 3 {
+		t.Fatalf("height %d after two blocks, want 3", height)
+	}
+	if !bytes.Equal(second.PrevHash, first.CurrHash) {
+		t.Fatal("second block doesn't extend the first")
+	}
+
+	block, err := chain.MineBlock(miner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.PrevHash = first.CurrHash
+	if err := chain.AddBlock(block); !errors.Is(err, ErrPrevHash) {
+		t.Fatalf("got %v, want ErrPrevHash", err)
+	}
+	if height, _ := chain.Height(); height != 3 {
+		t.Fatalf("height %d after a rejected block, want 3", height)
+	}
+}
+
+func assertTip(t *testing.T, chain *BlockChain, height uint64, tip *Block) {
+	t.Helper()
+	if got, _ := chain.Height(); got != height {
+		t.Fatalf("height %d, want %d", got, height)
+	}
+	got, err := chain.Tip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.CurrHash, tip.CurrHash) {
+		t.Fatalf("tip %x, want %x", got.CurrHash, tip.CurrHash)
+	}
+}
+
+func TestHeightAndTip(t *testing.T) {
+	if _, err := emptyChain(t).Tip(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("tip of an empty chain: got %v, want ErrNotFound", err)
+	}
+	miner := testUsers()[0]
+	chain := newTestChain(t, miner.Address())
+	genesis, _ := chain.GetBlock(0)
+	assertTip(t, chain, 1, genesis)
+	block := mine(t, chain, miner)
+	assertTip(t, chain, 2, block)
+
+	tip, _ := chain.Tip()
+	tip.Mapping[miner.Address()] = 0
+	if again, _ := chain.Tip(); again.Mapping[miner.Address()] == 0 {
+		t.Fatal("changing the returned tip changed the cached one")
+	}
+}
+
+func TestHeightAfterRollback(t *testing.T) {
+	// four light blocks replaced by three heavier ones
+	slow, fast := slowAndFast(t, 4, 3)
+	last, _ := slow.GetBlock(4)
+	assertTip(t, slow, 5, last)
+	fork := blocksAfter(t, fast, 0)
+	if replaced, err := slow.ResolveFork(fork); err != nil || !replaced {
+		t.Fatalf("got %v, %v", replaced, err)
+	}
+	assertTip(t, slow, 4, fork[len(fork)-1])
+	block := mine(t, slow, testUsers()[1])
+	assertTip(t, slow, 5, block)
+}
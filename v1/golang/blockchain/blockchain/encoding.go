@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// blockJSON is Block without its methods, so it encodes with the default rules.
+type blockJSON Block
+
+// MarshalCanonical encodes block as JSON that is byte-identical on every node:
+// fields are in declaration order, Mapping keys sorted and Timestamp in UTC.
+func (block *Block) MarshalCanonical() ([]byte, error) {
+	b := *block
+	b.Timestamp = b.Timestamp.UTC()
+	return json.Marshal((*blockJSON)(&b))
+}
+
+// UnmarshalJSON also accepts blocks stored or sent before the fields had JSON tags,
+// which are keyed by the Go field names.
+func (block *Block) UnmarshalJSON(data []byte) error {
+	var probe struct {
+		CurrHash json.RawMessage `json:"CurrHash"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	if probe.CurrHash == nil {
+		return json.Unmarshal(data, (*blockJSON)(block))
+	}
+	var legacy legacyBlock
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	*block = Block{
+		CurrHash:   legacy.CurrHash,
+		PrevHash:   legacy.PrevHash,
+		Nonce:      legacy.Nonce,
+		Difficulty: legacy.Difficulty,
+		Miner:      legacy.Miner,
+		Signature:  legacy.Signature,
+		MinerKey:   legacy.MinerKey,
+		Timestamp:  legacy.Timestamp,
+		Mapping:    legacy.Mapping,
+	}
+	if legacy.Transactions != nil {
+		block.Transactions = make([]Transaction, len(legacy.Transactions))
+		for i, tx := range legacy.Transactions {
+			block.Transactions[i] = Transaction(tx)
+		}
+	}
+	return nil
+}
+
+// legacyBlock and legacyTransaction are the untagged formats.
+type legacyBlock struct {
+	CurrHash     []byte
+	PrevHash     []byte
+	Nonce        uint64
+	Difficulty   uint8
+	Miner        string
+	Signature    []byte
+	MinerKey     []byte
+	Timestamp    time.Time
+	Transactions []legacyTransaction
+	Mapping      map[string]uint64
+}
+
+type legacyTransaction struct {
+	RandBytes []byte
+	PrevBlock []byte
+	Sender    string
+	Receiver  string
+	Value     uint64
+	ToStorage uint64
+	CurrHash  []byte
+	Signature []byte
+	PublicKey []byte
+}
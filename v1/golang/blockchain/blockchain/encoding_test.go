@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalCanonicalStable(t *testing.T) {
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("account%02d", i)
+	}
+	block := func() *Block {
+		block := testBlock()
+		for _, k := range keys {
+			block.Mapping[k] = uint64(len(k))
+		}
+		return block
+	}
+	want, err := block().MarshalCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		b := block()
+		b.Timestamp = b.Timestamp.In(time.FixedZone("", 3600*(i%24-12)))
+		got, err := b.MarshalCanonical()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("iteration %d:\n%s\nwant\n%s", i, got, want)
+		}
+	}
+}
+
+func TestBlockJSON(t *testing.T) {
+	block := testBlock()
+	hash := block.Hash()
+	data, err := block.MarshalCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"txs":`) || strings.Contains(string(data), `"Transactions"`) {
+		t.Fatalf("not the tagged field names: %s", data)
+	}
+	var got Block
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Hash(), hash) {
+		t.Fatal("the decoded block hashes differently")
+	}
+
+	// blocks stored before the fields had tags
+	legacy := legacyBlock{
+		CurrHash:     []byte{7},
+		PrevHash:     block.PrevHash,
+		Nonce:        block.Nonce,
+		Difficulty:   block.Difficulty,
+		Miner:        block.Miner,
+		Timestamp:    block.Timestamp,
+		Transactions: []legacyTransaction{legacyTransaction(block.Transactions[0])},
+		Mapping:      block.Mapping,
+	}
+	if data, err = json.Marshal(legacy); err != nil {
+		t.Fatal(err)
+	}
+	got = Block{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Hash(), hash) || !bytes.Equal(got.CurrHash, []byte{7}) {
+		t.Fatalf("legacy block decoded as %+v", got)
+	}
+}
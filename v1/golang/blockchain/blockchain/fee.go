@@ -0,0 +1,33 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var ErrFeeTooLow = errors.New("blockchain: transaction fee too low")
+
+// Fee is the ToStorage a transfer of value must carry at the FeeRate of the chain,
+// see GenesisConfig. The fee is never below StorageReward.
+func (chain *BlockChain) Fee(value uint64) uint64 {
+	return fee(value, chain.params().FeeRate)
+}
+
+func fee(value, rate uint64) uint64 {
+	hi, lo := bits.Mul64(value, rate)
+	if hi >= 10000 {
+		// the fee doesn't fit in uint64, no balance can cover such a transfer
+		return math.MaxUint64
+	}
+	fee, _ := bits.Div64(hi, lo, 10000)
+	return max(fee, StorageReward)
+}
+
+// splitFees divides the fees of a block between the miner, who gets share percent,
+// and StorageChain.
+func splitFees(fees, share uint64) (miner, storage uint64) {
+	hi, lo := bits.Mul64(fees, min(share, 100))
+	miner, _ = bits.Div64(hi, lo, 100)
+	return miner, fees - miner
+}
@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFee(t *testing.T) {
+	tests := []struct{ value, rate, want uint64 }{
+		{1000, 0, StorageReward},
+		{1000, 100, 10},
+		{50, 100, StorageReward}, // below the minimum
+		{math.MaxUint64, 10000, math.MaxUint64},
+		{math.MaxUint64, 20000, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		if got := fee(tt.value, tt.rate); got != tt.want {
+			t.Errorf("fee(%d, %d) = %d, want %d", tt.value, tt.rate, got, tt.want)
+		}
+	}
+	if miner, storage := splitFees(10, 40); miner != 4 || storage != 6 {
+		t.Errorf("splitFees(10, 40) = %d, %d", miner, storage)
+	}
+	if miner, storage := splitFees(10, 150); miner != 10 || storage != 0 {
+		t.Errorf("splitFees(10, 150) = %d, %d", miner, storage)
+	}
+}
+
+func TestTransferPaysFee(t *testing.T) {
+	users := testUsers()
+	alice, miner, bob := users[0], users[1], users[2]
+	cfg := DefaultGenesisConfig(alice.Address())
+	cfg.TargetBlockTime = time.Nanosecond
+	cfg.GenesisReward = 10000
+	cfg.FeeRate = 100 // 1%
+	cfg.MinerFeeShare = 40
+	chain, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := newTx(t, chain, alice, bob.Address(), 1000)
+	if tx.ToStorage != 10 {
+		t.Fatalf("fee %d, want 10", tx.ToStorage)
+	}
+	mine(t, chain, miner, *tx)
+	assertBalances(t, chain, map[string]uint64{
+		alice.Address(): 10000 - 1000 - 10,
+		bob.Address():   1000,
+		miner.Address(): MiningReward + 4,
+		StorageChain:    StorageValue + 6,
+	})
+
+	// the fee counts against the balance
+	if _, err := chain.NewTransaction(bob, alice.Address(), 1000); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	low := *newTx(t, chain, bob, alice.Address(), 500)
+	low.ToStorage--
+	if err := bob.SignTransaction(&low); err != nil {
+		t.Fatal(err)
+	}
+	if err := mineErr(chain, miner, low); !errors.Is(err, ErrFeeTooLow) {
+		t.Fatalf("got %v, want ErrFeeTooLow", err)
+	}
+}
@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"math/big"
+)
+
+var ErrNoCommonAncestor = errors.New("blockchain: fork doesn't branch off the chain")
+
+// ResolveFork replaces the local blocks after the parent of candidate[0] with candidate
+// when candidate carries strictly more work, the sum of 2^Difficulty, than them. Every
+// candidate block must pass checkBlock on top of the blocks before it. Balances follow the new tip since they are read from the stored
+// Mappings. It reports whether the chain was replaced.
+func (chain *BlockChain) ResolveFork(candidate []*Block) (bool, error) {
+	if len(candidate) == 0 {
+		return false, nil
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	ancestor, err := chain.store.BlockIndex(candidate[0].PrevHash)
+	if errors.Is(err, ErrNotFound) {
+		return false, ErrNoCommonAncestor
+	}
+	if err != nil {
+		return false, err
+	}
+	tip, err := chain.store.TotalDifficulty(chain.index - 1)
+	if err != nil {
+		return false, err
+	}
+	base, err := chain.store.TotalDifficulty(ancestor)
+	if err != nil {
+		return false, err
+	}
+	local := new(big.Int).Sub(tip, base)
+	fork := new(big.Int)
+	for _, block := range candidate {
+		fork.Add(fork, work(block.Difficulty))
+	}
+	if fork.Cmp(local) <= 0 {
+		return false, nil
+	}
+	prev, err := chain.GetBlock(ancestor)
+	if err != nil {
+		return false, err
+	}
+	for i, block := range candidate {
+		if err := chain.checkBlock(block, prev, ancestor, candidate[:i]); err != nil {
+			return false, &InvalidBlockError{Index: ancestor + 1 + uint64(i), Reason: err.Error()}
+		}
+		prev = block
+	}
+
+	if err := chain.store.PutBlocks(ancestor+1, candidate); err != nil {
+		return false, err
+	}
+	chain.index = ancestor + 1 + uint64(len(candidate))
+	chain.tip = cloneBlock(candidate[len(candidate)-1])
+	return true, nil
+}
+
+// checkBlock runs the checks every block after genesis must pass. block must follow
+// prev, which is the last of the fork blocks before or, without them, the local block
+// at parent. It must have the Difficulty difficultyAfter gives it, a valid proof of
+// work and miner signature, and its transactions must apply on top of the balances
+// before it, leaving exactly its Mapping, without replaying an earlier transaction.
+func (chain *BlockChain) checkBlock(block, prev *Block, parent uint64, before []*Block) error {
+	if !bytes.Equal(block.PrevHash, prev.CurrHash) {
+		return ErrPrevHash
+	}
+	if err := chain.checkDifficulty(block, parent, before); err != nil {
+		return err
+	}
+	if !block.IsValidProof() {
+		return ErrProofOfWork
+	}
+	if !verifyMinerSignature(block) {
+		return ErrBlockSignature
+	}
+	mapping, err := chain.checkTransactions(block, parent, before)
+	if err != nil {
+		return err
+	}
+	if !maps.Equal(mapping, block.Mapping) {
+		return ErrMapping
+	}
+	return chain.checkReplay(block, parent, before)
+}
@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// branch is a chain sharing the genesis block of chain, blocks mined on it form a
+// fork of chain.
+func branch(t *testing.T, chain *BlockChain) *BlockChain {
+	t.Helper()
+	genesis, err := chain.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork := emptyChain(t)
+	if err := fork.AddBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	return fork
+}
+
+// blocksAfter returns the blocks of chain after index.
+func blocksAfter(t *testing.T, chain *BlockChain, index uint64) []*Block {
+	t.Helper()
+	height, _ := chain.Height()
+	var blocks []*Block
+	for i := index + 1; i < height; i++ {
+		block, err := chain.GetBlock(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func assertBalances(t *testing.T, chain *BlockChain, want map[string]uint64) {
+	t.Helper()
+	for address, balance := range want {
+		got, err := chain.Balance(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != balance {
+			t.Errorf("balance of %.8s is %d, want %d", address, got, balance)
+		}
+	}
+}
+
+func TestResolveForkLonger(t *testing.T) {
+	users := testUsers()
+	alice, bob, carol := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	fork := branch(t, chain)
+
+	mine(t, chain, bob, *newTx(t, chain, alice, bob.Address(), 10))
+	mine(t, chain, bob)
+	mine(t, chain, bob)
+	mine(t, fork, carol, *newTx(t, fork, alice, carol.Address(), 20))
+	mine(t, fork, carol)
+	mine(t, fork, carol)
+
+	// as much work as the local blocks: the local chain stays
+	replaced, err := chain.ResolveFork(blocksAfter(t, fork, 0))
+	if err != nil || replaced {
+		t.Fatalf("equal fork: got %v, %v", replaced, err)
+	}
+	fee := chain.Fee(10)
+	assertBalances(t, chain, map[string]uint64{
+		alice.Address(): GenesisReward - 10 - fee,
+		bob.Address():   10 + 3*MiningReward,
+		carol.Address(): 0,
+	})
+
+	mine(t, fork, carol)
+	replaced, err = chain.ResolveFork(blocksAfter(t, fork, 0))
+	if err != nil || !replaced {
+		t.Fatalf("longer fork: got %v, %v", replaced, err)
+	}
+	if height, _ := chain.Height(); height != 5 {
+		t.Fatalf("height %d, want 5", height)
+	}
+	tip, _ := chain.Tip()
+	forkTip, _ := fork.Tip()
+	if !bytes.Equal(tip.CurrHash, forkTip.CurrHash) {
+		t.Fatal("tip isn't the fork tip")
+	}
+	fee = chain.Fee(20)
+	assertBalances(t, chain, map[string]uint64{
+		alice.Address(): GenesisReward - 20 - fee,
+		bob.Address():   0,
+		carol.Address(): 20 + 4*MiningReward,
+	})
+}
+
+func TestResolveForkInvalid(t *testing.T) {
+	users := testUsers()
+	alice, bob := users[0], users[1]
+	chain := newTestChain(t, alice.Address())
+	fork := branch(t, chain)
+	mine(t, chain, alice)
+	mine(t, fork, bob)
+	mine(t, fork, bob)
+	blocks := blocksAfter(t, fork, 0)
+	blocks[1].Mapping[bob.Address()]++
+	if replaced, err := chain.ResolveFork(blocks); err == nil || replaced {
+		t.Fatalf("fork with a forged Mapping: got %v, %v", replaced, err)
+	}
+	if height, _ := chain.Height(); height != 2 {
+		t.Fatalf("height %d after a rejected fork, want 2", height)
+	}
+}
+
+// mineAt mines an empty block on chain as if it was found at timestamp, which sets
+// the difficulty of the blocks after it.
+func mineAt(t *testing.T, chain *BlockChain, miner *User, timestamp time.Time) {
+	t.Helper()
+	block, err := chain.MineBlock(miner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Timestamp = timestamp
+	if err := block.Proof(context.Background(), block.Difficulty); err != nil {
+		t.Fatal(err)
+	}
+	if err := miner.SignBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// slowAndFast returns a chain with blocks mined far apart, so their difficulty stays
+// at the minimum, and a fork of it with blocks mined right away, so theirs rises.
+func slowAndFast(t *testing.T, slowBlocks, fastBlocks int) (slow, fast *BlockChain) {
+	t.Helper()
+	users := testUsers()
+	cfg := DefaultGenesisConfig(users[0].Address())
+	cfg.TargetBlockTime = time.Hour
+	slow, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast = branch(t, slow)
+	genesis, _ := slow.GetBlock(0)
+	for i := 1; i <= slowBlocks; i++ {
+		mineAt(t, slow, users[1], genesis.Timestamp.Add(time.Duration(i)*10*time.Hour))
+	}
+	for i := 0; i < fastBlocks; i++ {
+		mine(t, fast, users[2])
+	}
+	return slow, fast
+}
+
+func TestResolveForkHeavier(t *testing.T) {
+	slow, fast := slowAndFast(t, 3, 3)
+	heavy, _ := fast.TotalDifficulty()
+	light, _ := slow.TotalDifficulty()
+	if heavy.Cmp(light) <= 0 {
+		t.Fatalf("fast fork work %v, slow chain work %v", heavy, light)
+	}
+	replaced, err := slow.ResolveFork(blocksAfter(t, fast, 0))
+	if err != nil || !replaced {
+		t.Fatalf("heavier fork of equal length: got %v, %v", replaced, err)
+	}
+	if total, _ := slow.TotalDifficulty(); total.Cmp(heavy) != 0 {
+		t.Fatalf("total difficulty %v after the switch, want %v", total, heavy)
+	}
+}
+
+func TestResolveForkLongerButLighter(t *testing.T) {
+	slow, fast := slowAndFast(t, 4, 3)
+	replaced, err := fast.ResolveFork(blocksAfter(t, slow, 0))
+	if err != nil || replaced {
+		t.Fatalf("longer but lighter fork: got %v, %v", replaced, err)
+	}
+	if height, _ := fast.Height(); height != 4 {
+		t.Fatalf("height %d, want 4", height)
+	}
+}
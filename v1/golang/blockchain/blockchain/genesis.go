@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+)
+
+var ErrGenesisConfig = errors.New("blockchain: invalid genesis config")
+
+// GenesisConfig sets up the genesis block, e.g. for test networks. It is stored in
+// the Genesis field of the block and covered by its hash, so chains with different
+// configs have different genesis blocks.
+type GenesisConfig struct {
+	Receiver        string        `json:"receiver"`     // credited with GenesisReward
+	GenesisReward   uint64        `json:"reward"`       // initial balance of Receiver
+	StorageValue    uint64        `json:"storage"`      // initial balance of StorageChain
+	TargetBlockTime time.Duration `json:"blockTime"`    // 0 keeps the package TargetBlockTime
+	ReplayWindow    uint64        `json:"replayWindow"` // 0 keeps the package ReplayWindow
+	FeeRate         uint64        `json:"feeRate"`      // storage fee of a transfer in basis points of its Value
+	MinerFeeShare   uint64        `json:"minerShare"`   // percentage of the fees of a block credited to its miner
+}
+
+// DefaultGenesisConfig is the genesis of NewChain.
+func DefaultGenesisConfig(receiver string) GenesisConfig {
+	return GenesisConfig{Receiver: receiver, GenesisReward: GenesisReward, StorageValue: StorageValue}
+}
+
+func (cfg GenesisConfig) validate() error {
+	switch cfg.Receiver {
+	case "":
+		return fmt.Errorf("%w: empty Receiver", ErrGenesisConfig)
+	case StorageChain:
+		return fmt.Errorf("%w: Receiver %s is reserved", ErrGenesisConfig, cfg.Receiver)
+	}
+	if _, overflow := addUint64(cfg.StorageValue, cfg.GenesisReward, false); overflow {
+		return fmt.Errorf("%w: StorageValue %d + GenesisReward %d overflows",
+			ErrGenesisConfig, cfg.StorageValue, cfg.GenesisReward)
+	}
+	if cfg.MinerFeeShare > 100 {
+		return fmt.Errorf("%w: MinerFeeShare %d%% over 100%%", ErrGenesisConfig, cfg.MinerFeeShare)
+	}
+	if cfg.TargetBlockTime < 0 {
+		return fmt.Errorf("%w: negative TargetBlockTime %s", ErrGenesisConfig, cfg.TargetBlockTime)
+	}
+	return nil
+}
+
+// hash writes the fields of cfg into the hash of its genesis block.
+func (cfg *GenesisConfig) hash(h hash.Hash) {
+	writeBytes(h, []byte(cfg.Receiver))
+	writeUint64(h, cfg.GenesisReward)
+	writeUint64(h, cfg.StorageValue)
+	writeUint64(h, uint64(cfg.TargetBlockTime))
+	writeUint64(h, cfg.ReplayWindow)
+	writeUint64(h, cfg.FeeRate)
+	writeUint64(h, cfg.MinerFeeShare)
+}
+
+// setConfig records the consensus parameters of genesis, legacy genesis blocks
+// without a config use the defaults.
+func (chain *BlockChain) setConfig(genesis *Block) {
+	cfg := GenesisConfig{Receiver: genesis.Miner}
+	if genesis.Genesis != nil {
+		cfg = *genesis.Genesis
+	}
+	if cfg.ReplayWindow == 0 {
+		cfg.ReplayWindow = ReplayWindow
+	}
+	if cfg.TargetBlockTime == 0 {
+		cfg.TargetBlockTime = TargetBlockTime
+	}
+	chain.config.Store(&cfg)
+}
+
+// params returns the consensus parameters of the chain, the defaults while it has
+// no genesis block.
+func (chain *BlockChain) params() GenesisConfig {
+	if cfg := chain.config.Load(); cfg != nil {
+		return *cfg
+	}
+	return GenesisConfig{ReplayWindow: ReplayWindow, TargetBlockTime: TargetBlockTime}
+}
+
+// NewChainWithConfig is NewChain with the genesis block set up by cfg.
+// The file isn't created when cfg is invalid.
+func NewChainWithConfig(filename string, cfg GenesisConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	store, err := CreateSQLiteStore(filename)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	_, err = newChainWithConfig(store, cfg)
+	return err
+}
+
+func newChainWithConfig(store Store, cfg GenesisConfig) (*BlockChain, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	chain, err := OpenChain(store)
+	if err != nil {
+		return nil, err
+	}
+	genesis := &Block{
+		Mapping:   make(map[string]uint64),
+		Miner:     cfg.Receiver,
+		Timestamp: time.Now(),
+		Genesis:   &cfg,
+	}
+	genesis.Mapping[StorageChain] = cfg.StorageValue
+	genesis.Mapping[cfg.Receiver] = cfg.GenesisReward
+	genesis.CurrHash = genesis.Hash()
+	if err := chain.AddBlock(genesis); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewChainWithConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chain.db")
+	cfg := GenesisConfig{Receiver: "alice", GenesisReward: 5000, StorageValue: 700, TargetBlockTime: time.Minute}
+	if err := NewChainWithConfig(file, cfg); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := LoadChain(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	genesis, err := chain.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genesis.Mapping["alice"] != 5000 || genesis.Mapping[StorageChain] != 700 || len(genesis.Mapping) != 2 {
+		t.Fatalf("genesis Mapping %v", genesis.Mapping)
+	}
+	if genesis.Genesis == nil || *genesis.Genesis != cfg {
+		t.Fatalf("genesis config %+v, want %+v", genesis.Genesis, cfg)
+	}
+	if got := chain.params().TargetBlockTime; got != time.Minute {
+		t.Fatalf("target block time %s, want 1m", got)
+	}
+	assertBalances(t, chain, map[string]uint64{"alice": 5000, StorageChain: 700})
+}
+
+func TestNewChainWithConfigInvalid(t *testing.T) {
+	for name, cfg := range map[string]GenesisConfig{
+		"no receiver":        {GenesisReward: 1},
+		"storage receiver":   {Receiver: StorageChain},
+		"overflow":           {Receiver: "alice", GenesisReward: math.MaxUint64, StorageValue: 1},
+		"miner share":        {Receiver: "alice", MinerFeeShare: 101},
+		"negative blocktime": {Receiver: "alice", TargetBlockTime: -time.Second},
+	} {
+		file := filepath.Join(t.TempDir(), "chain.db")
+		if err := NewChainWithConfig(file, cfg); !errors.Is(err, ErrGenesisConfig) {
+			t.Errorf("%s: got %v, want ErrGenesisConfig", name, err)
+		}
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: file created for an invalid config", name)
+		}
+	}
+}
+
+func TestGenesisConfigInHash(t *testing.T) {
+	a := newTestChain(t, "alice")
+	cfg := DefaultGenesisConfig("alice")
+	cfg.TargetBlockTime = time.Nanosecond
+	cfg.ReplayWindow = 7 // not reflected in the Mapping
+	b, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ga, _ := a.GetBlock(0)
+	gb, _ := b.GetBlock(0)
+	ga.Timestamp = gb.Timestamp
+	if string(ga.Hash()) == string(gb.Hash()) {
+		t.Fatal("chains with different configs have the same genesis hash")
+	}
+}
@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"hash"
+	"sort"
+)
+
+// Hash is the SHA-256 over every block field except CurrHash, Signature and MinerKey,
+// transactions are covered by their MerkleRoot and the Genesis config, when set, by
+// its fields. MinerKey is bound through Miner.
+// Variable length fields are length prefixed and Mapping is hashed in key order,
+// so the result is the same on every machine.
+func (block *Block) Hash() []byte {
+	h := sha256.New()
+	writeBytes(h, block.PrevHash)
+	writeUint64(h, block.Nonce)
+	writeUint64(h, uint64(block.Difficulty))
+	writeBytes(h, []byte(block.Miner))
+	writeUint64(h, uint64(block.Timestamp.UnixNano()))
+	writeUint64(h, uint64(len(block.Transactions)))
+	writeBytes(h, MerkleRoot(block.Transactions))
+	keys := make([]string, 0, len(block.Mapping))
+	for k := range block.Mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	writeUint64(h, uint64(len(keys)))
+	for _, k := range keys {
+		writeBytes(h, []byte(k))
+		writeUint64(h, block.Mapping[k])
+	}
+	if block.Genesis != nil {
+		block.Genesis.hash(h)
+	}
+	return h.Sum(nil)
+}
+
+// VerifyBlockSignature reports whether block.Miner is the address of pub and
+// block.Signature is a valid signature by pub over the block hash.
+func VerifyBlockSignature(block *Block, pub *rsa.PublicKey) bool {
+	if block.Miner != AddressFromPublicKey(pub) {
+		return false
+	}
+	return rsa.VerifyPSS(pub, crypto.SHA256, block.Hash(), block.Signature, nil) == nil
+}
+
+// verifyMinerSignature checks block against the miner key it carries.
+func verifyMinerSignature(block *Block) bool {
+	pub, err := x509.ParsePKCS1PublicKey(block.MinerKey)
+	if err != nil {
+		return false
+	}
+	return VerifyBlockSignature(block, pub)
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	writeUint64(h, uint64(len(b)))
+	h.Write(b)
+}
+
+func writeUint64(h hash.Hash, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	h.Write(buf[:])
+}
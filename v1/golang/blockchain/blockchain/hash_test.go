@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testBlock() *Block {
+	return &Block{
+		PrevHash:   []byte{1, 2, 3},
+		Nonce:      42,
+		Difficulty: 3,
+		Miner:      "miner",
+		Timestamp:  time.Unix(1700000000, 5),
+		Transactions: []Transaction{
+			{RandBytes: []byte{9}, Sender: "a", Receiver: "b", Value: 10, ToStorage: 1},
+		},
+		Mapping: map[string]uint64{"a": 1, "b": 2, "c": 3, "d": 4},
+	}
+}
+
+func TestBlockHashStable(t *testing.T) {
+	block := testBlock()
+	hash := block.Hash()
+	if !bytes.Equal(hash, block.Hash()) {
+		t.Fatal("hashing twice gave different hashes")
+	}
+	// the same Mapping built in another order
+	other := testBlock()
+	other.Mapping = make(map[string]uint64)
+	for _, k := range []string{"d", "b", "c", "a"} {
+		other.Mapping[k] = block.Mapping[k]
+	}
+	other.CurrHash, other.Signature, other.MinerKey = []byte{1}, []byte{2}, []byte{3}
+	if !bytes.Equal(hash, other.Hash()) {
+		t.Fatal("equal blocks have different hashes")
+	}
+}
+
+func TestBlockHashCoversFields(t *testing.T) {
+	hash := testBlock().Hash()
+	tests := map[string]func(*Block){
+		"PrevHash":     func(b *Block) { b.PrevHash = []byte{1, 2, 4} },
+		"Nonce":        func(b *Block) { b.Nonce++ },
+		"Difficulty":   func(b *Block) { b.Difficulty++ },
+		"Miner":        func(b *Block) { b.Miner = "other" },
+		"Timestamp":    func(b *Block) { b.Timestamp = b.Timestamp.Add(time.Nanosecond) },
+		"Transactions": func(b *Block) { b.Transactions[0].Value++ },
+		"no txs":       func(b *Block) { b.Transactions = nil },
+		"Mapping":      func(b *Block) { b.Mapping["a"]++ },
+		"Mapping key":  func(b *Block) { b.Mapping["e"] = 0 },
+		"Genesis":      func(b *Block) { b.Genesis = &GenesisConfig{Receiver: "a"} },
+	}
+	for name, change := range tests {
+		block := testBlock()
+		change(block)
+		if bytes.Equal(hash, block.Hash()) {
+			t.Errorf("changing %s keeps the hash", name)
+		}
+	}
+}
+
+func TestVerifyBlockSignature(t *testing.T) {
+	users := testUsers()
+	alice, bob, carol := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	block, err := chain.MineBlock(bob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyBlockSignature(block, &bob.PrivateKey.PublicKey) {
+		t.Fatal("the miner's signature doesn't verify")
+	}
+	if VerifyBlockSignature(block, &carol.PrivateKey.PublicKey) {
+		t.Fatal("bob's block verifies with carol's key")
+	}
+
+	// carol signs, claiming bob mined it
+	forged := *block
+	if err := carol.SignBlock(&forged); err != nil {
+		t.Fatal(err)
+	}
+	forged.Miner = bob.Address()
+	if VerifyBlockSignature(&forged, &bob.PrivateKey.PublicKey) {
+		t.Fatal("carol's signature verifies as bob's")
+	}
+	if err := chain.AddBlock(&forged); !errors.Is(err, ErrBlockSignature) {
+		t.Fatalf("got %v, want ErrBlockSignature", err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testUsers are generated once, RSA keys are slow to make.
+var testUsers = sync.OnceValue(func() []*User {
+	users := make([]*User, 3)
+	for i := range users {
+		user, err := NewUser()
+		if err != nil {
+			panic(err)
+		}
+		users[i] = user
+	}
+	return users
+})
+
+// newTestChain is a memory chain crediting receiver. Its target block time is so short
+// that the difficulty stays at MinDifficulty.
+func newTestChain(t testing.TB, receiver string) *BlockChain {
+	t.Helper()
+	cfg := DefaultGenesisConfig(receiver)
+	cfg.TargetBlockTime = time.Nanosecond
+	chain, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// mine mines txs on the tip of chain and adds the block.
+func mine(t testing.TB, chain *BlockChain, miner *User, txs ...Transaction) *Block {
+	t.Helper()
+	block, err := chain.MineBlock(miner, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// newTx is a signed transfer of value from user to receiver on top of the tip of chain.
+func newTx(t testing.TB, chain *BlockChain, user *User, receiver string, value uint64) *Transaction {
+	t.Helper()
+	tx, err := chain.NewTransaction(user, receiver, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// emptyChain is a memory chain without a genesis block, as a node starts before its first sync.
+func emptyChain(t testing.TB) *BlockChain {
+	t.Helper()
+	chain, err := OpenChain(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
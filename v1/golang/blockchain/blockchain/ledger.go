@@ -0,0 +1,29 @@
+package blockchain
+
+import "math"
+
+// Balance of address as of the chain tip: the Mapping entry of the latest block
+// that touched the account, 0 if none did.
+func (chain *BlockChain) Balance(address string) (uint64, error) {
+	return chain.balanceAt(address, math.MaxInt64)
+}
+
+// balanceAt is the balance of address after the block at index.
+func (chain *BlockChain) balanceAt(address string, index uint64) (uint64, error) {
+	height, err := chain.store.Height()
+	if err != nil || height == 0 {
+		return 0, err
+	}
+	for i := min(index, height-1); ; i-- {
+		block, err := chain.store.GetBlock(i)
+		if err != nil {
+			return 0, err
+		}
+		if balance, ok := block.Mapping[address]; ok {
+			return balance, nil
+		}
+		if i == 0 {
+			return 0, nil
+		}
+	}
+}
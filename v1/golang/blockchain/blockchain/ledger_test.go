@@ -0,0 +1,32 @@
+package blockchain
+
+import "testing"
+
+func TestBalance(t *testing.T) {
+	users := testUsers()
+	alice, miner, bob := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	mine(t, chain, miner, *newTx(t, chain, alice, bob.Address(), 10))
+	mine(t, chain, miner, *newTx(t, chain, bob, alice.Address(), 5))
+
+	fee := chain.Fee(10)
+	if fee != chain.Fee(5) {
+		t.Fatalf("fees of 10 and 5 differ: %d, %d", fee, chain.Fee(5))
+	}
+	want := map[string]uint64{
+		alice.Address(): GenesisReward - 10 - fee + 5,
+		bob.Address():   10 - 5 - fee,
+		miner.Address(): 2 * MiningReward,
+		StorageChain:    StorageValue + 2*fee,
+		"nobody":        0,
+	}
+	for address, balance := range want {
+		got, err := chain.Balance(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != balance {
+			t.Errorf("balance of %.8s is %d, want %d", address, got, balance)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrBadSignature      = errors.New("blockchain: invalid transaction signature")
+	ErrInsufficientFunds = errors.New("blockchain: insufficient funds")
+	ErrDuplicate         = errors.New("blockchain: transaction already pending")
+	ErrMempoolFull       = errors.New("blockchain: mempool full")
+)
+
+// DefaultMempoolSize is the MaxSize of pools made by NewMempool.
+const DefaultMempoolSize = 10000
+
+// Mempool holds validated transactions waiting to be mined, in arrival order.
+//
+// Once MaxSize transactions are pending, Add makes room by evicting the transaction
+// Evict orders first, the oldest one on ties. The new transaction is rejected with
+// ErrMempoolFull unless Evict orders that victim strictly before it: on a tie the
+// pending transaction stays. A nil Evict evicts the oldest.
+type Mempool struct {
+	MaxSize int                          // 0 means no limit
+	Evict   func(a, b *Transaction) bool // reports whether a is evicted before b, e.g. LowestFeeFirst
+
+	chain *BlockChain
+	mu    sync.Mutex
+	txs   []*Transaction
+	known map[string]bool
+}
+
+func NewMempool(chain *BlockChain) *Mempool {
+	return &Mempool{MaxSize: DefaultMempoolSize, chain: chain, known: make(map[string]bool)}
+}
+
+// LowestFeeFirst is an Evict policy dropping the transaction paying the lowest ToStorage fee.
+func LowestFeeFirst(a, b *Transaction) bool {
+	return a.ToStorage < b.ToStorage
+}
+
+// Add validates tx and queues it. It is rejected when the signature is invalid, the fee
+// is below Fee, it replays a mined transaction or the sender's balance can't cover it
+// together with the sender's other pending transactions.
+func (pool *Mempool) Add(tx *Transaction) error {
+	if !verifySignature(tx) {
+		return ErrBadSignature
+	}
+	if need := pool.chain.Fee(tx.Value); tx.ToStorage < need {
+		return fmt.Errorf("%w: pays %d, needs %d", ErrFeeTooLow, tx.ToStorage, need)
+	}
+	height, err := pool.chain.Height()
+	if err != nil {
+		return err
+	}
+	if height == 0 {
+		return ErrNotFound
+	}
+	if err := pool.chain.checkReplay(&Block{Transactions: []Transaction{*tx}}, height-1, nil); err != nil {
+		return err
+	}
+	balance, err := pool.chain.Balance(tx.Sender)
+	if err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	key := hex.EncodeToString(tx.CurrHash)
+	if pool.known[key] {
+		return ErrDuplicate
+	}
+	spent, overflow := txCost(tx)
+	for _, pending := range pool.txs {
+		if pending.Sender == tx.Sender {
+			if bytes.Equal(pending.RandBytes, tx.RandBytes) {
+				return fmt.Errorf("%w: %x reuses the RandBytes of pending %x", ErrReplay, tx.CurrHash, pending.CurrHash)
+			}
+			cost, _ := txCost(pending)
+			spent, overflow = addUint64(spent, cost, overflow)
+		}
+	}
+	if overflow || spent > balance {
+		return fmt.Errorf("%w: %s has %d, pending spend %d", ErrInsufficientFunds, tx.Sender, balance, spent)
+	}
+	if pool.MaxSize > 0 && len(pool.txs) >= pool.MaxSize {
+		victim := pool.victim()
+		if pool.Evict != nil && !pool.Evict(pool.txs[victim], tx) {
+			return fmt.Errorf("%w: %d transactions pending", ErrMempoolFull, len(pool.txs))
+		}
+		delete(pool.known, hex.EncodeToString(pool.txs[victim].CurrHash))
+		pool.txs = append(pool.txs[:victim], pool.txs[victim+1:]...)
+	}
+	pool.txs = append(pool.txs, tx)
+	pool.known[key] = true
+	return nil
+}
+
+// victim is the index of the pending transaction to evict first.
+func (pool *Mempool) victim() int {
+	victim := 0
+	if pool.Evict == nil {
+		return victim
+	}
+	for i, tx := range pool.txs[1:] {
+		if pool.Evict(tx, pool.txs[victim]) {
+			victim = i + 1
+		}
+	}
+	return victim
+}
+
+// Pending returns the queued transactions in arrival order.
+func (pool *Mempool) Pending() []*Transaction {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return append([]*Transaction(nil), pool.txs...)
+}
+
+// Remove drops the transactions with the given CurrHash values, e.g. once they are mined.
+func (pool *Mempool) Remove(hashes [][]byte) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	drop := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		drop[hex.EncodeToString(hash)] = true
+	}
+	kept := pool.txs[:0]
+	for _, tx := range pool.txs {
+		key := hex.EncodeToString(tx.CurrHash)
+		if drop[key] {
+			delete(pool.known, key)
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	pool.txs = kept
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMempoolEvictTies(t *testing.T) {
+	alice, bob := testUsers()[0], testUsers()[1]
+	chain := newTestChain(t, alice.Address())
+	pool := NewMempool(chain)
+	pool.MaxSize, pool.Evict = 2, LowestFeeFirst
+
+	first := newTx(t, chain, alice, bob.Address(), 1)
+	second := newTx(t, chain, alice, bob.Address(), 1)
+	for _, tx := range []*Transaction{first, second} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// same fee as both pending transactions: the pending ones stay
+	tied := newTx(t, chain, alice, bob.Address(), 1)
+	if err := pool.Add(tied); !errors.Is(err, ErrMempoolFull) {
+		t.Fatalf("tied transaction: got %v, want ErrMempoolFull", err)
+	}
+	assertPending(t, pool, first, second)
+
+	// a higher fee evicts the oldest of the tied transactions
+	richer := newTx(t, chain, alice, bob.Address(), 1)
+	richer.ToStorage++
+	if err := alice.SignTransaction(richer); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(richer); err != nil {
+		t.Fatal(err)
+	}
+	assertPending(t, pool, second, richer)
+}
+
+func TestMempoolEvictOldest(t *testing.T) {
+	alice, bob := testUsers()[0], testUsers()[1]
+	chain := newTestChain(t, alice.Address())
+	pool := NewMempool(chain)
+	pool.MaxSize = 1
+
+	first := newTx(t, chain, alice, bob.Address(), 1)
+	second := newTx(t, chain, alice, bob.Address(), 1)
+	for _, tx := range []*Transaction{first, second} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertPending(t, pool, second)
+	if err := pool.Add(first); err != nil {
+		t.Fatalf("evicted transaction is re-added: %v", err)
+	}
+}
+
+func assertPending(t *testing.T, pool *Mempool, want ...*Transaction) {
+	t.Helper()
+	got := pool.Pending()
+	if len(got) != len(want) {
+		t.Fatalf("%d pending transactions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i].CurrHash, want[i].CurrHash) {
+			t.Fatalf("pending[%d] = %x, want %x", i, got[i].CurrHash, want[i].CurrHash)
+		}
+	}
+}
+
+func TestMempoolMined(t *testing.T) {
+	users := testUsers()
+	alice, miner, bob := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	pool := NewMempool(chain)
+	for i := 0; i < 3; i++ {
+		if err := pool.Add(newTx(t, chain, alice, bob.Address(), 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		txs    []Transaction
+		hashes [][]byte
+	)
+	for _, tx := range pool.Pending() {
+		txs = append(txs, *tx)
+		hashes = append(hashes, tx.CurrHash)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("%d pending transactions, want 3", len(txs))
+	}
+	mine(t, chain, miner, txs...)
+	pool.Remove(hashes)
+	if pending := pool.Pending(); len(pending) != 0 {
+		t.Fatalf("%d transactions pending after mining", len(pending))
+	}
+	if balance, _ := chain.Balance(bob.Address()); balance != 30 {
+		t.Fatalf("receiver balance %d, want 30", balance)
+	}
+	// mined transactions can't be queued again
+	if err := pool.Add(&txs[0]); err == nil {
+		t.Fatal("a mined transaction was accepted again")
+	}
+}
+
+func TestMempoolRejects(t *testing.T) {
+	alice, bob := testUsers()[0], testUsers()[1]
+	chain := newTestChain(t, alice.Address())
+	pool := NewMempool(chain)
+
+	tampered := newTx(t, chain, alice, bob.Address(), 10)
+	tampered.Value++
+	if err := pool.Add(tampered); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered transaction: got %v, want ErrBadSignature", err)
+	}
+	// each transfer is affordable alone, both exceed the balance
+	half := uint64(GenesisReward/2 + 1)
+	if err := pool.Add(newTx(t, chain, alice, bob.Address(), half)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(newTx(t, chain, alice, bob.Address(), half)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("double spend: got %v, want ErrInsufficientFunds", err)
+	}
+}
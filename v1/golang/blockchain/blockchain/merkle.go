@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrMerkleIndex = errors.New("blockchain: transaction index out of range")
+
+// Leaves and inner nodes are hashed with different prefixes so a proof can't
+// pass an inner node off as a transaction.
+const (
+	merkleLeafPrefix  = 0
+	merkleInnerPrefix = 1
+)
+
+// MerkleLeaf is the SHA-256 over every field of tx, including CurrHash and Signature.
+func MerkleLeaf(tx *Transaction) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	writeBytes(h, tx.RandBytes)
+	writeBytes(h, tx.PrevBlock)
+	writeBytes(h, []byte(tx.Sender))
+	writeBytes(h, []byte(tx.Receiver))
+	writeUint64(h, tx.Value)
+	writeUint64(h, tx.ToStorage)
+	writeBytes(h, tx.CurrHash)
+	writeBytes(h, tx.Signature)
+	writeBytes(h, tx.PublicKey)
+	return h.Sum(nil)
+}
+
+// MerkleRoot is the root of the tree over the MerkleLeaf of each transaction.
+// A level with an odd number of nodes pairs its last node with itself.
+// The root of no transactions is the SHA-256 of nothing.
+func MerkleRoot(txs []Transaction) []byte {
+	if len(txs) == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+	level := merkleLeaves(txs)
+	for len(level) > 1 {
+		level = merkleLevel(level)
+	}
+	return level[0]
+}
+
+// MerkleProof returns the sibling hashes from the leaf of txs[index] up to the root.
+func MerkleProof(txs []Transaction, index int) ([][]byte, error) {
+	if index < 0 || index >= len(txs) {
+		return nil, ErrMerkleIndex
+	}
+	var proof [][]byte
+	level := merkleLeaves(txs)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling == len(level) {
+			sibling = index
+		}
+		proof = append(proof, level[sibling])
+		level = merkleLevel(level)
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof reports whether proof leads from leaf, a MerkleLeaf, to root.
+func VerifyMerkleProof(root, leaf []byte, proof [][]byte) bool {
+	hash := leaf
+	for _, sibling := range proof {
+		hash = merkleNode(hash, sibling)
+	}
+	return bytes.Equal(hash, root)
+}
+
+func merkleLeaves(txs []Transaction) [][]byte {
+	leaves := make([][]byte, len(txs))
+	for i := range txs {
+		leaves[i] = MerkleLeaf(&txs[i])
+	}
+	return leaves
+}
+
+func merkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		next = append(next, merkleNode(level[i], right))
+	}
+	return next
+}
+
+// merkleNode hashes the pair in sorted order, so proofs don't need to record
+// on which side each sibling is.
+func merkleNode(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	h := sha256.New()
+	h.Write([]byte{merkleInnerPrefix})
+	h.Write(a)
+	h.Write(b)
+	return h.Sum(nil)
+}
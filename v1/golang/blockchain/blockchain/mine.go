@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MineBlock builds the block after the tip from txs and runs proof of work at
+// NextDifficulty. There is no coinbase transaction: the block Mapping credits the
+// miner MiningReward plus the MinerFeeShare of the chain of the ToStorage fees of txs
+// directly, the rest of the fees goes to StorageChain.
+// The block is signed by miner but isn't stored, pass it to AddBlock.
+func (chain *BlockChain) MineBlock(miner *User, txs []Transaction) (*Block, error) {
+	tip, err := chain.LastBlock()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := chain.store.BlockIndex(tip.CurrHash)
+	if err != nil {
+		return nil, err
+	}
+	block := &Block{
+		PrevHash:     tip.CurrHash,
+		Miner:        miner.Address(),
+		Timestamp:    time.Now(),
+		Transactions: txs,
+	}
+	if block.Mapping, err = chain.checkTransactions(block, parent, nil); err != nil {
+		return nil, err
+	}
+	if err := block.Proof(context.Background(), chain.NextDifficulty()); err != nil {
+		return nil, err
+	}
+	if err := miner.SignBlock(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// applyTransactions fills block.Mapping with the balances after its transactions
+// and the miner reward and fees, starting from the balances before the block given by
+// balanceOf. Fees follow the FeeRate and MinerFeeShare of cfg.
+// Transactions are applied in order, each against the balances left by the ones
+// before it, so a sender can't spend the same funds twice in a block.
+func applyTransactions(block *Block, cfg GenesisConfig, balanceOf func(address string) (uint64, error)) error {
+	balance := func(address string) (uint64, error) {
+		if b, ok := block.Mapping[address]; ok {
+			return b, nil
+		}
+		return balanceOf(address)
+	}
+	var fees uint64
+	overflow := false
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if !verifySignature(tx) {
+			return fmt.Errorf("%w: %x", ErrBadSignature, tx.CurrHash)
+		}
+		if need := fee(tx.Value, cfg.FeeRate); tx.ToStorage < need {
+			return fmt.Errorf("%w: transaction %x pays %d, needs %d", ErrFeeTooLow, tx.CurrHash, tx.ToStorage, need)
+		}
+		cost, over := txCost(tx)
+		from, err := balance(tx.Sender)
+		if err != nil {
+			return err
+		}
+		if over || cost > from {
+			return fmt.Errorf("%w: transaction %x: %s has %d, needs %d",
+				ErrInsufficientFunds, tx.CurrHash, tx.Sender, from, cost)
+		}
+		block.Mapping[tx.Sender] = from - cost
+		to, err := balance(tx.Receiver)
+		if err != nil {
+			return err
+		}
+		block.Mapping[tx.Receiver], overflow = addUint64(to, tx.Value, overflow)
+		fees, overflow = addUint64(fees, tx.ToStorage, overflow)
+	}
+	minerFees, storageFees := splitFees(fees, cfg.MinerFeeShare)
+	if storageFees > 0 {
+		storage, err := balance(StorageChain)
+		if err != nil {
+			return err
+		}
+		block.Mapping[StorageChain], overflow = addUint64(storage, storageFees, overflow)
+	}
+	reward, err := balance(block.Miner)
+	if err != nil {
+		return err
+	}
+	reward, overflow = addUint64(reward, MiningReward, overflow)
+	block.Mapping[block.Miner], overflow = addUint64(reward, minerFees, overflow)
+	if overflow {
+		return fmt.Errorf("blockchain: balance overflow")
+	}
+	return nil
+}
+
+// checkTransactions applies the transactions of block, which follows the fork blocks
+// before on top of the local block at parent, to a scratch ledger and returns the
+// Mapping they produce, or the first transaction that can't be applied. A valid block
+// carries exactly that Mapping.
+func (chain *BlockChain) checkTransactions(block *Block, parent uint64, before []*Block) (map[string]uint64, error) {
+	balanceOf := func(address string) (uint64, error) {
+		for i := len(before) - 1; i >= 0; i-- {
+			if balance, ok := before[i].Mapping[address]; ok {
+				return balance, nil
+			}
+		}
+		return chain.balanceAt(address, parent)
+	}
+	scratch := Block{
+		Miner:        block.Miner,
+		Transactions: block.Transactions,
+		Mapping:      make(map[string]uint64),
+	}
+	if err := applyTransactions(&scratch, chain.params(), balanceOf); err != nil {
+		return nil, err
+	}
+	return scratch.Mapping, nil
+}
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMineEmptyBlock(t *testing.T) {
+	users := testUsers()
+	owner, miner := users[0], users[1]
+	chain := newTestChain(t, owner.Address())
+	before, err := chain.Balance(miner.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, _ := chain.Balance(StorageChain)
+	block := mine(t, chain, miner)
+	if len(block.Transactions) != 0 {
+		t.Fatalf("empty block carries %d transactions", len(block.Transactions))
+	}
+	after, err := chain.Balance(miner.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before != MiningReward {
+		t.Fatalf("miner balance rose by %d, want %d", after-before, MiningReward)
+	}
+	if got, _ := chain.Balance(StorageChain); got != storage {
+		t.Fatalf("storage balance changed from %d to %d without fees", storage, got)
+	}
+	if got, _ := chain.Balance(owner.Address()); got != GenesisReward {
+		t.Fatalf("owner balance changed to %d", got)
+	}
+}
+
+func TestDoubleSpendInBlock(t *testing.T) {
+	users := testUsers()
+	alice, miner, bob := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	// each transfer is covered by the balance, both are not
+	value := uint64(GenesisReward/2 + 1)
+	first := newTx(t, chain, alice, bob.Address(), value)
+	second := newTx(t, chain, alice, miner.Address(), value)
+
+	_, err := chain.MineBlock(miner, []Transaction{*first, *second})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%x", second.CurrHash)) {
+		t.Fatalf("error %q doesn't name the second transaction", err)
+	}
+	mine(t, chain, miner, *first)
+}
+
+func TestForgedMappingRejected(t *testing.T) {
+	users := testUsers()
+	alice, attacker, bob := users[0], users[1], users[2]
+	chain := newTestChain(t, alice.Address())
+	block, err := chain.MineBlock(attacker, []Transaction{*newTx(t, chain, alice, bob.Address(), 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a valid proof and signature over a Mapping crediting the attacker more
+	block.Mapping[attacker.Address()] += 1000
+	if err := block.Proof(context.Background(), block.Difficulty); err != nil {
+		t.Fatal(err)
+	}
+	if err := attacker.SignBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); !errors.Is(err, ErrMapping) {
+		t.Fatalf("got %v, want ErrMapping", err)
+	}
+}
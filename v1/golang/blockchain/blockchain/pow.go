@@ -0,0 +1,152 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"time"
+)
+
+// Difficulty adjustment, see NextDifficulty.
+const (
+	MinDifficulty = 1
+	MaxDifficulty = 64
+)
+
+var ErrDifficulty = errors.New("blockchain: wrong block difficulty")
+
+const (
+	// TargetBlockTime is the average interval between blocks NextDifficulty aims for
+	// unless the GenesisConfig of the chain sets one.
+	TargetBlockTime = 10 * time.Second
+	// DifficultyWindow is the number of most recent block intervals averaged.
+	DifficultyWindow = 10
+)
+
+// NextDifficulty is the difficulty for the block after the tip. Each extra bit doubles
+// the expected mining time, so the tip difficulty is raised by one when the average
+// interval over the last DifficultyWindow blocks is under half of the target block time
+// of the chain, TargetBlockTime unless its GenesisConfig sets one, and
+// lowered by one when it is over twice of it. The result is clamped to
+// [MinDifficulty, MaxDifficulty]; MinDifficulty is returned when the chain can't be read.
+// AddBlock rejects blocks of any other difficulty.
+func (chain *BlockChain) NextDifficulty() uint8 {
+	height, err := chain.store.Height()
+	if err != nil || height == 0 {
+		return MinDifficulty
+	}
+	difficulty, err := chain.difficultyAfter(height-1, nil)
+	if err != nil {
+		return MinDifficulty
+	}
+	return difficulty
+}
+
+// difficultyAfter is NextDifficulty for the block following the fork blocks before,
+// which branch off after the local block at parent.
+func (chain *BlockChain) difficultyAfter(parent uint64, before []*Block) (uint8, error) {
+	var (
+		newest, oldest time.Time
+		difficulty     uint8
+		count          int
+	)
+	visit := func(block *Block) {
+		if count == 0 {
+			newest, difficulty = block.Timestamp, block.Difficulty
+		}
+		oldest = block.Timestamp
+		count++
+	}
+	for i := len(before) - 1; i >= 0 && count <= DifficultyWindow; i-- {
+		visit(before[i])
+	}
+	for i := parent; count <= DifficultyWindow; i-- {
+		block, err := chain.store.GetBlock(i)
+		if err != nil {
+			return 0, err
+		}
+		visit(block)
+		if i == 0 {
+			break
+		}
+	}
+	next := int(difficulty)
+	if count > 1 {
+		target := chain.params().TargetBlockTime
+		average := newest.Sub(oldest) / time.Duration(count-1)
+		switch {
+		case average < target/2:
+			next++
+		case average > target*2:
+			next--
+		}
+	}
+	return uint8(min(max(next, MinDifficulty), MaxDifficulty)), nil
+}
+
+// checkDifficulty reports a block whose Difficulty isn't the one difficultyAfter gives it.
+func (chain *BlockChain) checkDifficulty(block *Block, parent uint64, before []*Block) error {
+	want, err := chain.difficultyAfter(parent, before)
+	if err != nil {
+		return err
+	}
+	if block.Difficulty != want {
+		return fmt.Errorf("%w: %d, want %d", ErrDifficulty, block.Difficulty, want)
+	}
+	return nil
+}
+
+// Proof mines the block: it increments Nonce until Hash has at least difficulty
+// leading zero bits, then sets Difficulty and CurrHash.
+// It stops with ctx.Err() when ctx is done, e.g. when a competing block arrives.
+func (block *Block) Proof(ctx context.Context, difficulty uint8) error {
+	block.Difficulty = difficulty
+	for {
+		if block.Nonce%1024 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		hash := block.Hash()
+		if leadingZeroBits(hash) >= int(difficulty) {
+			block.CurrHash = hash
+			return nil
+		}
+		block.Nonce++
+	}
+}
+
+// IsValidProof reports whether CurrHash is the block hash and meets the stored Difficulty.
+func (block *Block) IsValidProof() bool {
+	hash := block.Hash()
+	return bytes.Equal(hash, block.CurrHash) && leadingZeroBits(hash) >= int(block.Difficulty)
+}
+
+// work is the expected number of hashes to mine a block at difficulty, 2^difficulty.
+func work(difficulty uint8) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(difficulty))
+}
+
+// TotalDifficulty is the sum of 2^Difficulty over all blocks, the work behind the tip.
+func (chain *BlockChain) TotalDifficulty() (*big.Int, error) {
+	height, err := chain.store.Height()
+	if err != nil {
+		return nil, err
+	}
+	if height == 0 {
+		return new(big.Int), nil
+	}
+	return chain.store.TotalDifficulty(height - 1)
+}
+
+func leadingZeroBits(hash []byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
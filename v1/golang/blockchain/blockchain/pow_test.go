@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// spacedChain is a chain of n blocks of difficulty mined interval apart, stored
+// without validation.
+func spacedChain(t *testing.T, n int, difficulty uint8, interval time.Duration) *BlockChain {
+	t.Helper()
+	start := time.Unix(1700000000, 0)
+	blocks := make([]*Block, n)
+	for i := range blocks {
+		blocks[i] = &Block{
+			CurrHash:   []byte{byte(i)},
+			Difficulty: difficulty,
+			Timestamp:  start.Add(time.Duration(i) * interval),
+			Mapping:    map[string]uint64{},
+		}
+	}
+	store := NewMemoryStore()
+	if err := store.PutBlocks(0, blocks); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := OpenChain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestNextDifficulty(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty uint8
+		interval   time.Duration
+		want       uint8
+	}{
+		{"fast", 5, TargetBlockTime / 10, 6},
+		{"slow", 5, TargetBlockTime * 3, 4},
+		{"on target", 5, TargetBlockTime, 5},
+		{"fast at max", MaxDifficulty, time.Second, MaxDifficulty},
+		{"slow at min", MinDifficulty, time.Hour, MinDifficulty},
+	}
+	for _, tt := range tests {
+		chain := spacedChain(t, DifficultyWindow+5, tt.difficulty, tt.interval)
+		if got := chain.NextDifficulty(); got != tt.want {
+			t.Errorf("%s: NextDifficulty() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMinedDifficultyRises(t *testing.T) {
+	miner := testUsers()[0]
+	cfg := DefaultGenesisConfig(miner.Address())
+	cfg.TargetBlockTime = time.Hour
+	chain, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last uint8
+	for i := 0; i < 4; i++ {
+		block := mine(t, chain, miner)
+		if block.Difficulty <= last {
+			t.Fatalf("block %d: difficulty %d after %d", i+1, block.Difficulty, last)
+		}
+		last = block.Difficulty
+	}
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"blockchain/network"
+)
+
+// Register serves the blockchain protocol options of the network package from chain.
+func (chain *BlockChain) Register(mux *network.Mux) {
+	mux.HandleFunc(network.OptionGetBlock, chain.handleGetBlock)
+	mux.HandleFunc(network.OptionGetLastHash, chain.handleGetLastHash)
+	mux.HandleFunc(network.OptionPushBlock, chain.handlePushBlock)
+	mux.HandleFunc(network.OptionGetHeight, chain.handleGetHeight)
+}
+
+// NewBlockPackage is a package with option carrying block as JSON in Data.
+func NewBlockPackage(option network.Option, block *Block) *network.Package {
+	return &network.Package{Option: option, Data: SerializeBlock(block)}
+}
+
+// BlockFromPackage parses the block in a package built by NewBlockPackage or
+// in an OptionGetBlock response.
+func BlockFromPackage(pack *network.Package) (*Block, error) {
+	if err := pack.Err(); err != nil {
+		return nil, err
+	}
+	block := DeserializeBlock(pack.Data)
+	if block == nil {
+		return nil, ErrDeserialize
+	}
+	return block, nil
+}
+
+func (chain *BlockChain) handleGetBlock(_ context.Context, pack *network.Package) (string, error) {
+	index, err := strconv.ParseUint(pack.Data, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("bad block index %q", pack.Data)
+	}
+	block, err := chain.GetBlock(index)
+	if err != nil {
+		return "", err
+	}
+	return SerializeBlock(block), nil
+}
+
+func (chain *BlockChain) handleGetLastHash(context.Context, *network.Package) (string, error) {
+	block, err := chain.LastBlock()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(block.CurrHash), nil
+}
+
+func (chain *BlockChain) handlePushBlock(ctx context.Context, pack *network.Package) (string, error) {
+	block, err := BlockFromPackage(pack)
+	if err != nil {
+		return "", err
+	}
+	if err := chain.AddBlock(block); err != nil {
+		return "", err
+	}
+	return chain.handleGetHeight(ctx, pack)
+}
+
+func (chain *BlockChain) handleGetHeight(context.Context, *network.Package) (string, error) {
+	height, err := chain.Height()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(height, 10), nil
+}
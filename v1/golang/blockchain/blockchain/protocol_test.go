@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"blockchain/network"
+)
+
+// serve answers the blockchain protocol from chain on a free local port until the test ends.
+func serve(t testing.TB, chain *BlockChain, opts ...network.ListenOption) string {
+	t.Helper()
+	mux := network.NewMux()
+	chain.Register(mux)
+	l, err := network.Listen("127.0.0.1:0", mux.ServeConn, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+func TestProtoNodes(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	block := mine(t, chain, users[1], *newTx(t, chain, users[0], users[2].Address(), 10))
+	address := serve(t, chain, network.UseCodec(network.ProtoCodec))
+
+	c, err := network.Dial(address, network.WithCodec(network.ProtoCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Send(&network.Package{Option: network.OptionGetBlock, Data: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := BlockFromPackage(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.CurrHash, block.CurrHash) || !bytes.Equal(got.Hash(), block.CurrHash) {
+		t.Fatal("block changed on the way")
+	}
+
+	// push it to a second proto node sharing the genesis block
+	other := emptyChain(t)
+	genesis, err := chain.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AddBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	otherAddress := serve(t, other, network.UseCodec(network.ProtoCodec))
+	c2, err := network.Dial(otherAddress, network.WithCodec(network.ProtoCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	res, err = c2.Send(NewBlockPackage(network.OptionPushBlock, got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "2" {
+		t.Fatalf("height after push %q, want 2", res.Data)
+	}
+}
+
+func TestServeBlocks(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	block := mine(t, chain, users[1], *newTx(t, chain, users[0], users[2].Address(), 10))
+	address := serve(t, chain)
+
+	res, err := network.Send(address, &network.Package{Option: network.OptionGetBlock, Data: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := BlockFromPackage(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.CurrHash, block.CurrHash) || len(got.Transactions) != 1 {
+		t.Fatalf("got block %x with %d transactions", got.CurrHash, len(got.Transactions))
+	}
+
+	res, err = network.Send(address, &network.Package{Option: network.OptionGetLastHash})
+	if err != nil || res.Data != hex.EncodeToString(block.CurrHash) {
+		t.Fatalf("last hash: got %v, %v", res, err)
+	}
+	res, err = network.Send(address, &network.Package{Option: network.OptionGetHeight})
+	if err != nil || res.Data != "2" {
+		t.Fatalf("height: got %v, %v", res, err)
+	}
+
+	var remote *network.RemoteError
+	if _, err := network.Send(address, &network.Package{Option: network.OptionGetBlock, Data: "7"}); !errors.As(err, &remote) {
+		t.Fatalf("missing block: got %v, want a remote error", err)
+	}
+	if _, err := network.Send(address, &network.Package{Option: network.OptionGetBlock, Data: "x"}); !errors.As(err, &remote) {
+		t.Fatalf("bad index: got %v, want a remote error", err)
+	}
+}
+
+func TestPushBlock(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	fork := branch(t, chain)
+	address := serve(t, chain)
+
+	block := mine(t, fork, users[1])
+	res, err := network.Send(address, NewBlockPackage(network.OptionPushBlock, block))
+	if err != nil || res.Data != "2" {
+		t.Fatalf("push: got %v, %v", res, err)
+	}
+	// the same block again no longer extends the tip
+	if _, err := network.Send(address, NewBlockPackage(network.OptionPushBlock, block)); err == nil {
+		t.Fatal("pushing a block twice succeeded")
+	}
+}
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ReplayWindow is how many blocks old the PrevBlock of a transaction may be when it is
+// mined, unless the GenesisConfig of the chain sets another window. Together with the
+// RandBytes a sender may use only once it stops a signed transaction from being
+// applied twice.
+const ReplayWindow = 100
+
+var (
+	ErrReplay         = errors.New("blockchain: transaction replayed")
+	ErrStalePrevBlock = errors.New("blockchain: transaction PrevBlock is not a recent block")
+)
+
+// checkReplay reports the first transaction of block that replays an earlier one
+// or refers to a PrevBlock outside the replay window of the chain. block follows the local block at
+// parent and the fork blocks before, which are not stored.
+//
+// A transaction is mined after its PrevBlock, so an earlier copy of it can only be
+// in the blocks following the oldest PrevBlock of block: only those are scanned.
+func (chain *BlockChain) checkReplay(block *Block, parent uint64, before []*Block) error {
+	if len(block.Transactions) == 0 {
+		return nil
+	}
+	window := chain.params().ReplayWindow
+	tip := parent + uint64(len(before))
+	oldest := tip
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		index, err := chain.prevBlockIndex(tx.PrevBlock, parent, before)
+		if err != nil {
+			return err
+		}
+		if tip-index >= window {
+			return fmt.Errorf("%w: transaction %x, block %d", ErrStalePrevBlock, tx.CurrHash, index)
+		}
+		oldest = min(oldest, index)
+	}
+	used := make(map[string]bool)
+	for index := oldest + 1; index <= parent; index++ {
+		b, err := chain.store.GetBlock(index)
+		if err != nil {
+			return err
+		}
+		addNonces(used, b)
+	}
+	for _, b := range before {
+		addNonces(used, b)
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		key := nonceKey(tx)
+		if used[key] {
+			return fmt.Errorf("%w: %x", ErrReplay, tx.CurrHash)
+		}
+		used[key] = true
+	}
+	return nil
+}
+
+// prevBlockIndex finds hash among the fork blocks before or the local blocks up to parent.
+func (chain *BlockChain) prevBlockIndex(hash []byte, parent uint64, before []*Block) (uint64, error) {
+	for i := len(before) - 1; i >= 0; i-- {
+		if bytes.Equal(before[i].CurrHash, hash) {
+			return parent + 1 + uint64(i), nil
+		}
+	}
+	index, err := chain.store.BlockIndex(hash)
+	if errors.Is(err, ErrNotFound) || err == nil && index > parent {
+		return 0, fmt.Errorf("%w: unknown block %x", ErrStalePrevBlock, hash)
+	}
+	return index, err
+}
+
+func addNonces(used map[string]bool, block *Block) {
+	for i := range block.Transactions {
+		used[nonceKey(&block.Transactions[i])] = true
+	}
+}
+
+func nonceKey(tx *Transaction) string {
+	return tx.Sender + "/" + string(tx.RandBytes)
+}
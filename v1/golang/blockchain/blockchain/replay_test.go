@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// mineErr mines txs on the tip of chain and adds the block, returning the first error.
+func mineErr(chain *BlockChain, miner *User, txs ...Transaction) error {
+	block, err := chain.MineBlock(miner, txs)
+	if err != nil {
+		return err
+	}
+	return chain.AddBlock(block)
+}
+
+func TestReplayRejected(t *testing.T) {
+	users := testUsers()
+	alice, bob := users[0], users[1]
+	chain := newTestChain(t, alice.Address())
+	tx := newTx(t, chain, alice, bob.Address(), 10)
+	mine(t, chain, bob, *tx)
+
+	if err := mineErr(chain, bob, *tx); !errors.Is(err, ErrReplay) {
+		t.Fatalf("got %v, want ErrReplay", err)
+	}
+	// a copy in the same block
+	fork := branch(t, chain)
+	if err := mineErr(fork, bob, *tx, *tx); err == nil {
+		t.Fatal("a transaction mined twice in one block")
+	}
+	assertBalances(t, chain, map[string]uint64{bob.Address(): 10 + MiningReward})
+}
+
+func TestStalePrevBlock(t *testing.T) {
+	users := testUsers()
+	alice, bob := users[0], users[1]
+	cfg := DefaultGenesisConfig(alice.Address())
+	cfg.TargetBlockTime = time.Nanosecond
+	cfg.ReplayWindow = 2
+	chain, err := newChainWithConfig(NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := newTx(t, chain, alice, bob.Address(), 10)
+	mine(t, chain, bob)
+	mine(t, chain, bob)
+	if err := mineErr(chain, bob, *tx); !errors.Is(err, ErrStalePrevBlock) {
+		t.Fatalf("got %v, want ErrStalePrevBlock", err)
+	}
+	unknown := *newTx(t, chain, alice, bob.Address(), 10)
+	unknown.PrevBlock = []byte("no such block")
+	if err := alice.SignTransaction(&unknown); err != nil {
+		t.Fatal(err)
+	}
+	if err := mineErr(chain, bob, unknown); !errors.Is(err, ErrStalePrevBlock) {
+		t.Fatalf("unknown PrevBlock: got %v, want ErrStalePrevBlock", err)
+	}
+}
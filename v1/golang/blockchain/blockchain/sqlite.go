@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"os"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// CreateTable holds one row per block, id is the block index starting at 0
+// for genesis and block is the JSON encoded Block. total_difficulty is the
+// big-endian sum of 2^difficulty over the block and all blocks before it.
+const (
+	CreateTable = `
+	create table block_chain (
+	    id         integer primary key,
+	    hash       blob    not null,
+	    prev_hash  blob,
+	    nonce      integer not null,
+	    difficulty integer not null,
+	    miner      text    not null,
+	    timestamp  integer not null,
+	    block      blob    not null,
+	    total_difficulty blob not null
+	);
+	create unique index block_chain_hash on block_chain (hash);
+	create index block_chain_prev_hash on block_chain (prev_hash);
+`
+)
+
+// SQLiteStore keeps blocks in the block_chain table of a sqlite database.
+type SQLiteStore struct {
+	DB *sql.DB
+}
+
+// CreateSQLiteStore creates filename, truncating an existing file, with an empty block_chain table.
+func CreateSQLiteStore(filename string) (*SQLiteStore, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(CreateTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{DB: db}, nil
+}
+
+// OpenSQLiteStore opens a store made by CreateSQLiteStore, upgrading older schemas.
+func OpenSQLiteStore(filename string) (*SQLiteStore, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLiteStore{DB: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) PutBlocks(index uint64, blocks []*Block) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("delete from block_chain where id >= ?", index); err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		if err := insertBlock(tx, index+uint64(i), block); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertBlock(tx *sql.Tx, index uint64, block *Block) error {
+	data := SerializeBlock(block)
+	if data == "" {
+		return errSerialize
+	}
+	total := work(block.Difficulty)
+	if index > 0 {
+		var prev []byte
+		err := tx.QueryRow("select total_difficulty from block_chain where id = ?", index-1).Scan(&prev)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		total.Add(total, new(big.Int).SetBytes(prev))
+	}
+	_, err := tx.Exec(
+		"insert into block_chain (id, hash, prev_hash, nonce, difficulty, miner, timestamp, block, total_difficulty) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		index, block.CurrHash, block.PrevHash, block.Nonce, block.Difficulty, block.Miner, block.Timestamp.UnixNano(), data, total.Bytes(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetBlock(index uint64) (*Block, error) {
+	var data string
+	err := s.DB.QueryRow("select block from block_chain where id = ?", index).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	block := DeserializeBlock(data)
+	if block == nil {
+		return nil, ErrDeserialize
+	}
+	return block, nil
+}
+
+func (s *SQLiteStore) BlockIndex(hash []byte) (uint64, error) {
+	var index uint64
+	err := s.DB.QueryRow("select id from block_chain where hash = ?", hash).Scan(&index)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return index, err
+}
+
+func (s *SQLiteStore) Height() (uint64, error) {
+	var height uint64
+	err := s.DB.QueryRow("select count(*) from block_chain").Scan(&height)
+	return height, err
+}
+
+func (s *SQLiteStore) TotalDifficulty(index uint64) (*big.Int, error) {
+	var total []byte
+	err := s.DB.QueryRow("select total_difficulty from block_chain where id = ?", index).Scan(&total)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(total), nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.DB.Close()
+}
+
+// migrate adds and fills total_difficulty in chains created before it existed.
+func (s *SQLiteStore) migrate() error {
+	var count int
+	err := s.DB.QueryRow(
+		"select count(*) from pragma_table_info('block_chain') where name = 'total_difficulty'").Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("alter table block_chain add column total_difficulty blob not null default x''"); err != nil {
+		return err
+	}
+	rows, err := tx.Query("select id, difficulty from block_chain order by id")
+	if err != nil {
+		return err
+	}
+	totals := make(map[uint64][]byte)
+	total := new(big.Int)
+	for rows.Next() {
+		var (
+			index      uint64
+			difficulty uint8
+		)
+		if err := rows.Scan(&index, &difficulty); err != nil {
+			rows.Close()
+			return err
+		}
+		total.Add(total, work(difficulty))
+		totals[index] = total.Bytes()
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for index, total := range totals {
+		if _, err := tx.Exec("update block_chain set total_difficulty = ? where id = ?", total, index); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
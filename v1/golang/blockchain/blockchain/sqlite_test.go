@@ -0,0 +1,104 @@
+package blockchain
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSQLiteTestChain is newTestChain in a sqlite file, it returns the file name.
+func newSQLiteTestChain(t testing.TB, receiver string) (*BlockChain, string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "chain.db")
+	store, err := CreateSQLiteStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultGenesisConfig(receiver)
+	cfg.TargetBlockTime = time.Nanosecond
+	chain, err := newChainWithConfig(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+	return chain, file
+}
+
+func TestNewChainGenesisRow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chain.db")
+	if err := NewChain(file, "receiver"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var (
+		count      int
+		hash, data []byte
+		miner      string
+	)
+	if err := db.QueryRow("select count(*) from block_chain").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("%d rows after NewChain, want 1", count)
+	}
+	if err := db.QueryRow("select hash, miner, block from block_chain where id = 0").Scan(&hash, &miner, &data); err != nil {
+		t.Fatal(err)
+	}
+	genesis := DeserializeBlock(string(data))
+	if genesis == nil {
+		t.Fatal("genesis block column doesn't decode")
+	}
+	if !bytes.Equal(hash, genesis.CurrHash) || miner != genesis.Miner {
+		t.Fatalf("row hash %x miner %q, block hash %x miner %q", hash, miner, genesis.CurrHash, genesis.Miner)
+	}
+	if genesis.Mapping["receiver"] != GenesisReward {
+		t.Fatalf("genesis credits %d, want %d", genesis.Mapping["receiver"], GenesisReward)
+	}
+}
+
+func TestGetBlock(t *testing.T) {
+	users := testUsers()
+	chain, file := newSQLiteTestChain(t, users[0].Address())
+	genesis, err := chain.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := mine(t, chain, users[1], *newTx(t, chain, users[0], users[2].Address(), 10))
+
+	loaded, err := LoadChain(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	for i, want := range []*Block{genesis, block} {
+		got, err := loaded.GetBlock(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Timestamp.Equal(want.Timestamp) {
+			t.Fatalf("block %d: timestamp %v, want %v", i, got.Timestamp, want.Timestamp)
+		}
+		got.Timestamp = want.Timestamp
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("block %d:\ngot  %+v\nwant %+v", i, got, want)
+		}
+	}
+	last, err := loaded.LastBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(last.CurrHash, block.CurrHash) {
+		t.Fatal("LastBlock isn't the mined block")
+	}
+	if _, err := loaded.GetBlock(2); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing block: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"sync"
+)
+
+// Store persists the blocks of a chain by index, genesis is at 0.
+// BlockChain serializes its writes, a Store must allow concurrent reads.
+type Store interface {
+	// PutBlocks atomically drops the blocks from index on and stores blocks at
+	// index, index+1, ... index may be at most Height.
+	PutBlocks(index uint64, blocks []*Block) error
+	// GetBlock returns the block at index or ErrNotFound.
+	GetBlock(index uint64) (*Block, error)
+	// BlockIndex returns the index of the block with hash or ErrNotFound.
+	BlockIndex(hash []byte) (uint64, error)
+	// Height is the number of blocks stored.
+	Height() (uint64, error)
+	// TotalDifficulty is the sum of 2^Difficulty over the blocks up to and including index.
+	TotalDifficulty(index uint64) (*big.Int, error)
+	Close() error
+}
+
+// MemoryStore keeps blocks in memory, for tests and ephemeral nodes.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	blocks []string // JSON encoded, so callers can't change stored blocks
+	keys   []string // hex hash of each block
+	totals []*big.Int
+	hashes map[string]uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{hashes: make(map[string]uint64)}
+}
+
+func (s *MemoryStore) PutBlocks(index uint64, blocks []*Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index > uint64(len(s.blocks)) {
+		return ErrNotFound
+	}
+	data := make([]string, len(blocks))
+	for i, block := range blocks {
+		if data[i] = SerializeBlock(block); data[i] == "" {
+			return errSerialize
+		}
+	}
+	for _, key := range s.keys[index:] {
+		delete(s.hashes, key)
+	}
+	s.blocks, s.keys, s.totals = s.blocks[:index], s.keys[:index], s.totals[:index]
+	for i, block := range blocks {
+		total := work(block.Difficulty)
+		if len(s.totals) > 0 {
+			total.Add(total, s.totals[len(s.totals)-1])
+		}
+		key := hex.EncodeToString(block.CurrHash)
+		s.hashes[key] = uint64(len(s.blocks))
+		s.blocks = append(s.blocks, data[i])
+		s.keys = append(s.keys, key)
+		s.totals = append(s.totals, total)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetBlock(index uint64) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if index >= uint64(len(s.blocks)) {
+		return nil, ErrNotFound
+	}
+	block := DeserializeBlock(s.blocks[index])
+	if block == nil {
+		return nil, ErrDeserialize
+	}
+	return block, nil
+}
+
+func (s *MemoryStore) BlockIndex(hash []byte) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index, ok := s.hashes[hex.EncodeToString(hash)]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return index, nil
+}
+
+func (s *MemoryStore) Height() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return uint64(len(s.blocks)), nil
+}
+
+func (s *MemoryStore) TotalDifficulty(index uint64) (*big.Int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if index >= uint64(len(s.totals)) {
+		return nil, ErrNotFound
+	}
+	return new(big.Int).Set(s.totals[index]), nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryChain(t *testing.T) {
+	users := testUsers()
+	alice, miner, bob := users[0], users[1], users[2]
+	chain, err := NewMemoryChain(alice.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	mine(t, chain, miner, *newTx(t, chain, alice, bob.Address(), 10))
+	mine(t, chain, miner, *newTx(t, chain, bob, alice.Address(), 5))
+
+	if height, _ := chain.Height(); height != 3 {
+		t.Fatalf("height %d, want 3", height)
+	}
+	if ok, err := chain.IsValid(); !ok || err != nil {
+		t.Fatalf("memory chain invalid: %v", err)
+	}
+	fee := chain.Fee(10)
+	assertBalances(t, chain, map[string]uint64{
+		alice.Address(): GenesisReward - 10 - fee + 5,
+		bob.Address():   10 - 5 - fee,
+		miner.Address(): 2 * MiningReward,
+	})
+}
+
+// stores makes an empty store of each implementation.
+var stores = map[string]func(t *testing.T) Store{
+	"memory": func(*testing.T) Store { return NewMemoryStore() },
+	"sqlite": func(t *testing.T) Store {
+		store, err := CreateSQLiteStore(filepath.Join(t.TempDir(), "chain.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	},
+}
+
+// storedBlock is testBlock with hash h and difficulty d.
+func storedBlock(h byte, d uint8) *Block {
+	block := testBlock()
+	block.CurrHash = []byte{h}
+	block.Difficulty = d
+	return block
+}
+
+func TestStore(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			if _, err := store.GetBlock(0); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("empty store: got %v, want ErrNotFound", err)
+			}
+			if err := store.PutBlocks(0, []*Block{storedBlock(1, 1), storedBlock(2, 2), storedBlock(3, 3)}); err != nil {
+				t.Fatal(err)
+			}
+			if height, _ := store.Height(); height != 3 {
+				t.Fatalf("height %d, want 3", height)
+			}
+			if block, err := store.GetBlock(1); err != nil || !bytes.Equal(block.CurrHash, []byte{2}) {
+				t.Fatalf("block 1: got %v, %v", block, err)
+			}
+			if index, err := store.BlockIndex([]byte{3}); err != nil || index != 2 {
+				t.Fatalf("index of block 3: got %d, %v", index, err)
+			}
+			if total, err := store.TotalDifficulty(2); err != nil || total.Int64() != 2+4+8 {
+				t.Fatalf("total difficulty: got %v, %v", total, err)
+			}
+
+			// replacing the blocks from 1 on drops the old ones
+			if err := store.PutBlocks(1, []*Block{storedBlock(4, 1)}); err != nil {
+				t.Fatal(err)
+			}
+			if height, _ := store.Height(); height != 2 {
+				t.Fatalf("height %d after replacing, want 2", height)
+			}
+			if _, err := store.BlockIndex([]byte{3}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("dropped block: got %v, want ErrNotFound", err)
+			}
+			if total, _ := store.TotalDifficulty(1); total.Int64() != 2+2 {
+				t.Fatalf("total difficulty %v after replacing, want 4", total)
+			}
+			if _, err := store.TotalDifficulty(2); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("got %v, want ErrNotFound", err)
+			}
+			if err := store.PutBlocks(5, []*Block{storedBlock(5, 1)}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("put past the height: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestChainOnStores(t *testing.T) {
+	users := testUsers()
+	alice, bob, carol := users[0], users[1], users[2]
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultGenesisConfig(alice.Address())
+			cfg.TargetBlockTime = time.Nanosecond
+			chain, err := newChainWithConfig(newStore(t), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fork := branch(t, chain)
+			mine(t, chain, bob, *newTx(t, chain, alice, bob.Address(), 10))
+			mine(t, fork, carol)
+			mine(t, fork, carol)
+			if replaced, err := chain.ResolveFork(blocksAfter(t, fork, 0)); err != nil || !replaced {
+				t.Fatalf("got %v, %v", replaced, err)
+			}
+			if ok, err := chain.IsValid(); !ok || err != nil {
+				t.Fatalf("chain invalid after the fork: %v", err)
+			}
+			assertBalances(t, chain, map[string]uint64{
+				alice.Address(): GenesisReward,
+				bob.Address():   0,
+				carol.Address(): 2 * MiningReward,
+			})
+		})
+	}
+}
+
+func TestStoreCorruptBlock(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			if err := store.PutBlocks(0, []*Block{storedBlock(1, 1)}); err != nil {
+				t.Fatal(err)
+			}
+			switch s := store.(type) {
+			case *MemoryStore:
+				s.blocks[0] = "{not json"
+			case *SQLiteStore:
+				if _, err := s.DB.Exec("update block_chain set block = ? where id = 0", "{not json"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if block, err := store.GetBlock(0); !errors.Is(err, ErrDeserialize) || block != nil {
+				t.Fatalf("got %v, %v, want ErrDeserialize", block, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"blockchain/network"
+)
+
+var ErrForeignChain = errors.New("blockchain: peer has a different genesis block")
+
+// SyncFrom downloads the blocks peer has beyond the local tip and appends them one by
+// one, so an interrupted sync continues from where it stopped. Each block is validated
+// by AddBlock like ResolveFork does. When the peer is on a fork the common ancestor is searched
+// backwards from the tip and the peer blocks after it are passed to ResolveFork.
+// An empty chain, a block_chain table without rows, starts with the peer's genesis block.
+func (chain *BlockChain) SyncFrom(peer string) error {
+	remote, err := fetchHeight(peer)
+	if err != nil {
+		return err
+	}
+	for {
+		local, err := chain.Height()
+		if err != nil {
+			return err
+		}
+		if local >= remote {
+			return nil
+		}
+		block, err := fetchBlock(peer, local)
+		if err != nil {
+			return err
+		}
+		if local == 0 {
+			// an empty chain takes the genesis block of the peer
+			if reason := validateBlock(block, nil); reason != "" {
+				return &InvalidBlockError{Index: 0, Reason: reason}
+			}
+			if err := chain.AddBlock(block); err != nil {
+				return err
+			}
+			continue
+		}
+		tip, err := chain.LastBlock()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(block.PrevHash, tip.CurrHash) {
+			return chain.syncFork(peer, local, remote)
+		}
+		if err := chain.AddBlock(block); err != nil {
+			return fmt.Errorf("blockchain: block %d from %s: %w", local, peer, err)
+		}
+	}
+}
+
+// SyncFromPeers syncs from the best known peers in turn until one succeeds,
+// reporting each outcome back to peers. A peer serving invalid blocks counts as failed.
+func (chain *BlockChain) SyncFromPeers(peers *network.PeerManager) error {
+	err := network.ErrNoPeers
+	for _, peer := range peers.Best(peers.Len()) {
+		if err = chain.SyncFrom(peer); err == nil {
+			peers.Good(peer, 0)
+			return nil
+		}
+		if errors.Is(err, ErrForeignChain) {
+			peers.Remove(peer)
+		} else {
+			peers.Bad(peer)
+		}
+	}
+	return err
+}
+
+// syncFork finds the last block below local that peer shares with the chain and
+// offers the peer blocks after it, up to remote, to ResolveFork.
+func (chain *BlockChain) syncFork(peer string, local, remote uint64) error {
+	ancestor := local - 1
+	for {
+		theirs, err := fetchBlock(peer, ancestor)
+		if err != nil {
+			return err
+		}
+		ours, err := chain.GetBlock(ancestor)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(theirs.CurrHash, ours.CurrHash) {
+			break
+		}
+		if ancestor == 0 {
+			return ErrForeignChain
+		}
+		ancestor--
+	}
+	fork := make([]*Block, 0, remote-ancestor-1)
+	for index := ancestor + 1; index < remote; index++ {
+		block, err := fetchBlock(peer, index)
+		if err != nil {
+			return err
+		}
+		fork = append(fork, block)
+	}
+	_, err := chain.ResolveFork(fork)
+	return err
+}
+
+func fetchHeight(peer string) (uint64, error) {
+	res, err := network.Send(peer, &network.Package{Option: network.OptionGetHeight})
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseUint(res.Data, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("blockchain: peer %s sent bad height %q", peer, res.Data)
+	}
+	return height, nil
+}
+
+func fetchBlock(peer string, index uint64) (*Block, error) {
+	res, err := network.Send(peer, &network.Package{Option: network.OptionGetBlock, Data: strconv.FormatUint(index, 10)})
+	if err != nil {
+		return nil, err
+	}
+	return BlockFromPackage(res)
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// sameBlocks fails unless both chains hold the same blocks.
+func sameBlocks(t *testing.T, got, want *BlockChain) {
+	t.Helper()
+	gotHeight, _ := got.Height()
+	wantHeight, _ := want.Height()
+	if gotHeight != wantHeight {
+		t.Fatalf("height %d, want %d", gotHeight, wantHeight)
+	}
+	for i := uint64(0); i < wantHeight; i++ {
+		a, err := got.GetBlock(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := want.GetBlock(i)
+		if !bytes.Equal(a.CurrHash, b.CurrHash) {
+			t.Fatalf("block %d is %x, want %x", i, a.CurrHash, b.CurrHash)
+		}
+	}
+}
+
+func TestSyncFrom(t *testing.T) {
+	users := testUsers()
+	alice, bob := users[0], users[1]
+	chain := newTestChain(t, alice.Address())
+	mine(t, chain, bob, *newTx(t, chain, alice, bob.Address(), 10))
+	for i := 0; i < 3; i++ {
+		mine(t, chain, bob)
+	}
+	peer := serve(t, chain)
+
+	node := emptyChain(t)
+	if err := node.SyncFrom(peer); err != nil {
+		t.Fatal(err)
+	}
+	sameBlocks(t, node, chain)
+	if ok, err := node.IsValid(); !ok || err != nil {
+		t.Fatalf("synced chain invalid: %v", err)
+	}
+	want, _ := chain.Balance(bob.Address())
+	assertBalances(t, node, map[string]uint64{bob.Address(): want})
+}
+
+func TestSyncFromResumes(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	mine(t, chain, users[1])
+	peer := serve(t, chain)
+
+	node := emptyChain(t)
+	if err := node.SyncFrom(peer); err != nil {
+		t.Fatal(err)
+	}
+	mine(t, chain, users[1])
+	mine(t, chain, users[1])
+	if err := node.SyncFrom(peer); err != nil {
+		t.Fatal(err)
+	}
+	sameBlocks(t, node, chain)
+}
+
+func TestSyncFromFork(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	node := branch(t, chain)
+	mine(t, node, users[2])
+	for i := 0; i < 3; i++ {
+		mine(t, chain, users[1])
+	}
+	peer := serve(t, chain)
+
+	if err := node.SyncFrom(peer); err != nil {
+		t.Fatal(err)
+	}
+	sameBlocks(t, node, chain)
+}
+
+func TestSyncFromForeignChain(t *testing.T) {
+	users := testUsers()
+	chain := newTestChain(t, users[0].Address())
+	mine(t, chain, users[1])
+	other := newTestChain(t, users[1].Address())
+	peer := serve(t, other)
+	mine(t, other, users[1])
+	mine(t, other, users[1])
+
+	if err := chain.SyncFrom(peer); !errors.Is(err, ErrForeignChain) {
+		t.Fatalf("got %v, want ErrForeignChain", err)
+	}
+}
@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/bits"
+)
+
+// NewTransaction builds a signed transaction of value from user to receiver on top
+// of the current tip, Fee(value) is charged on top of value as the storage fee.
+func (chain *BlockChain) NewTransaction(user *User, receiver string, value uint64) (*Transaction, error) {
+	tip, err := chain.LastBlock()
+	if err != nil {
+		return nil, err
+	}
+	tx := &Transaction{
+		RandBytes: make([]byte, RandBytesSize),
+		PrevBlock: tip.CurrHash,
+		Sender:    user.Address(),
+		Receiver:  receiver,
+		Value:     value,
+		ToStorage: chain.Fee(value),
+	}
+	if _, err := rand.Read(tx.RandBytes); err != nil {
+		return nil, err
+	}
+	cost, overflow := txCost(tx)
+	balance, err := chain.Balance(tx.Sender)
+	if err != nil {
+		return nil, err
+	}
+	if overflow || cost > balance {
+		return nil, fmt.Errorf("%w: %s has %d, needs %d", ErrInsufficientFunds, tx.Sender, balance, cost)
+	}
+	if err := user.SignTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Hash is the SHA-256 over RandBytes, PrevBlock, Sender, Receiver, Value and ToStorage,
+// the fields the signature covers. CurrHash, Signature and PublicKey are excluded.
+// Variable length fields are length prefixed, so the result is the same on every machine.
+func (tx *Transaction) Hash() []byte {
+	h := sha256.New()
+	writeBytes(h, tx.RandBytes)
+	writeBytes(h, tx.PrevBlock)
+	writeBytes(h, []byte(tx.Sender))
+	writeBytes(h, []byte(tx.Receiver))
+	writeUint64(h, tx.Value)
+	writeUint64(h, tx.ToStorage)
+	return h.Sum(nil)
+}
+
+// VerifyTransaction reports whether tx.Sender is the address of pub, tx.CurrHash is the
+// transaction hash and tx.Signature is a valid signature by pub over it.
+func VerifyTransaction(tx *Transaction, pub *rsa.PublicKey) bool {
+	if tx.Sender != AddressFromPublicKey(pub) {
+		return false
+	}
+	hash := tx.Hash()
+	if !bytes.Equal(hash, tx.CurrHash) {
+		return false
+	}
+	return rsa.VerifyPSS(pub, crypto.SHA256, hash, tx.Signature, nil) == nil
+}
+
+// verifySignature checks tx against the sender key it carries.
+func verifySignature(tx *Transaction) bool {
+	pub, err := x509.ParsePKCS1PublicKey(tx.PublicKey)
+	if err != nil {
+		return false
+	}
+	return VerifyTransaction(tx, pub)
+}
+
+// txCost is what tx debits from the sender, overflow reports a sum past uint64.
+func txCost(tx *Transaction) (uint64, bool) {
+	return addUint64(tx.Value, tx.ToStorage, false)
+}
+
+func addUint64(a, b uint64, overflow bool) (uint64, bool) {
+	sum, carry := bits.Add64(a, b, 0)
+	return sum, overflow || carry != 0
+}
@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVerifyTransaction(t *testing.T) {
+	users := testUsers()
+	user := users[0]
+	tx := &Transaction{
+		RandBytes: []byte{1, 2, 3},
+		PrevBlock: []byte{4, 5, 6},
+		Receiver:  users[1].Address(),
+		Value:     10,
+		ToStorage: 1,
+	}
+	if err := user.SignTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	pub := &user.PrivateKey.PublicKey
+	if !VerifyTransaction(tx, pub) {
+		t.Fatal("signed transaction doesn't verify")
+	}
+	if VerifyTransaction(tx, &users[1].PrivateKey.PublicKey) {
+		t.Fatal("transaction verifies under another key")
+	}
+
+	tampered := *tx
+	tampered.Value++
+	if VerifyTransaction(&tampered, pub) {
+		t.Fatal("transaction with a tampered Value verifies")
+	}
+	tampered.CurrHash = tampered.Hash()
+	if VerifyTransaction(&tampered, pub) {
+		t.Fatal("transaction with a tampered Value and recomputed hash verifies")
+	}
+}
+
+func TestNewTransaction(t *testing.T) {
+	users := testUsers()
+	alice, bob := users[0], users[1]
+	chain := newTestChain(t, alice.Address())
+	tip, err := chain.LastBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := newTx(t, chain, alice, bob.Address(), 10)
+	switch {
+	case tx.Sender != alice.Address() || tx.Receiver != bob.Address() || tx.Value != 10:
+		t.Fatalf("transfer %s -> %s of %d", tx.Sender, tx.Receiver, tx.Value)
+	case tx.ToStorage != chain.Fee(10):
+		t.Fatalf("fee %d, want %d", tx.ToStorage, chain.Fee(10))
+	case !bytes.Equal(tx.PrevBlock, tip.CurrHash):
+		t.Fatal("PrevBlock isn't the tip")
+	case len(tx.RandBytes) != RandBytesSize:
+		t.Fatalf("%d RandBytes, want %d", len(tx.RandBytes), RandBytesSize)
+	case !verifySignature(tx):
+		t.Fatal("transaction doesn't verify")
+	}
+	if other := newTx(t, chain, alice, bob.Address(), 10); bytes.Equal(other.RandBytes, tx.RandBytes) {
+		t.Fatal("two transactions share RandBytes")
+	}
+
+	// the whole balance leaves nothing for the fee
+	if _, err := chain.NewTransaction(alice, bob.Address(), GenesisReward); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	if _, err := chain.NewTransaction(bob, alice.Address(), 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("empty account: got %v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestTransactionHash(t *testing.T) {
+	tx := func() *Transaction {
+		return &Transaction{RandBytes: []byte{1}, PrevBlock: []byte{2}, Sender: "a", Receiver: "b", Value: 10, ToStorage: 1}
+	}
+	hash := tx().Hash()
+	if !bytes.Equal(hash, tx().Hash()) {
+		t.Fatal("equal transactions have different hashes")
+	}
+	signed := tx()
+	signed.CurrHash, signed.Signature, signed.PublicKey = []byte{3}, []byte{4}, []byte{5}
+	if !bytes.Equal(hash, signed.Hash()) {
+		t.Fatal("the hash covers CurrHash, Signature or PublicKey")
+	}
+
+	tests := map[string]func(*Transaction){
+		"RandBytes": func(tx *Transaction) { tx.RandBytes = []byte{9} },
+		"PrevBlock": func(tx *Transaction) { tx.PrevBlock = []byte{9} },
+		"Sender":    func(tx *Transaction) { tx.Sender = "c" },
+		"Receiver":  func(tx *Transaction) { tx.Receiver = "c" },
+		"Value":     func(tx *Transaction) { tx.Value++ },
+		"ToStorage": func(tx *Transaction) { tx.ToStorage++ },
+		// the length prefix keeps field boundaries apart
+		"boundary": func(tx *Transaction) { tx.Sender, tx.Receiver = "ab", "" },
+	}
+	for name, change := range tests {
+		other := tx()
+		change(other)
+		if bytes.Equal(hash, other.Hash()) {
+			t.Errorf("changing %s keeps the hash", name)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package blockchain
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KeySize    = 2048
+	MinKeySize = 2048
+)
+
+// NewUser generates a user with a fresh KeySize bits RSA key.
+func NewUser() (*User, error) {
+	return NewUserWithBits(KeySize)
+}
+
+// NewUserWithBits generates a user with a fresh RSA key of bits size,
+// sizes below MinKeySize are rejected.
+func NewUserWithBits(bits int) (*User, error) {
+	if bits < MinKeySize {
+		return nil, fmt.Errorf("blockchain: key size %d is below %d bits", bits, MinKeySize)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	return &User{PrivateKey: key}, nil
+}
+
+// Address of the user, used as Sender, Receiver, Miner and Mapping key.
+func (user *User) Address() string {
+	return AddressFromPublicKey(&user.PrivateKey.PublicKey)
+}
+
+// AddressFromPublicKey is the hex encoded SHA-256 of the PKCS#1 encoded public key.
+func AddressFromPublicKey(pub *rsa.PublicKey) string {
+	hash := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	return hex.EncodeToString(hash[:])
+}
+
+// SignTransaction sets tx.Sender and tx.PublicKey to the user address and key,
+// tx.CurrHash to the transaction hash and tx.Signature to an RSA-PSS signature over it.
+func (user *User) SignTransaction(tx *Transaction) error {
+	tx.Sender = user.Address()
+	tx.PublicKey = x509.MarshalPKCS1PublicKey(&user.PrivateKey.PublicKey)
+	hash := tx.Hash()
+	signature, err := rsa.SignPSS(rand.Reader, user.PrivateKey, crypto.SHA256, hash, nil)
+	if err != nil {
+		return err
+	}
+	tx.CurrHash = hash
+	tx.Signature = signature
+	return nil
+}
+
+// SignBlock sets block.Miner and block.MinerKey to the user address and key and
+// block.Signature to an RSA-PSS signature over the block hash. Sign after Proof,
+// the signature covers the nonce; setting Miner on a proven block invalidates the proof.
+func (user *User) SignBlock(block *Block) error {
+	block.Miner = user.Address()
+	block.MinerKey = x509.MarshalPKCS1PublicKey(&user.PrivateKey.PublicKey)
+	signature, err := rsa.SignPSS(rand.Reader, user.PrivateKey, crypto.SHA256, block.Hash(), nil)
+	if err != nil {
+		return err
+	}
+	block.Signature = signature
+	return nil
+}
+
+const (
+	pemPrivateKey          = "PRIVATE KEY"
+	pemRSAPrivateKey       = "RSA PRIVATE KEY"
+	pemEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+)
+
+var ErrPassphrase = errors.New("blockchain: wrong passphrase or corrupted key")
+
+// ExportPEM encodes the private key as PKCS#8 PEM.
+func (user *User) ExportPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(user.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+}
+
+// ExportEncryptedPEM encodes the private key as PKCS#8 encrypted with AES-256-GCM
+// under a key derived from passphrase by scrypt.
+func (user *User) ExportEncryptedPEM(passphrase string) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(user.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type: pemEncryptedPrivateKey,
+		Headers: map[string]string{
+			"Salt":  hex.EncodeToString(salt),
+			"Nonce": hex.EncodeToString(nonce),
+		},
+		Bytes: aead.Seal(nil, nonce, der, nil),
+	}), nil
+}
+
+// LoadUser decodes a user from a PKCS#1 or PKCS#8 PEM private key.
+func LoadUser(data []byte) (*User, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("blockchain: no PEM data found")
+	}
+	switch block.Type {
+	case pemRSAPrivateKey:
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &User{PrivateKey: key}, nil
+	case pemPrivateKey:
+		return parsePKCS8User(block.Bytes)
+	case pemEncryptedPrivateKey:
+		return nil, errors.New("blockchain: key is encrypted, use LoadEncryptedUser")
+	default:
+		return nil, fmt.Errorf("blockchain: unsupported PEM type %q", block.Type)
+	}
+}
+
+// LoadEncryptedUser decodes a user from a PEM made by ExportEncryptedPEM.
+func LoadEncryptedUser(data []byte, passphrase string) (*User, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("blockchain: no PEM data found")
+	}
+	if block.Type != pemEncryptedPrivateKey {
+		return nil, fmt.Errorf("blockchain: unsupported PEM type %q", block.Type)
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	der, err := aead.Open(nil, nonce, block.Bytes, nil)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	return parsePKCS8User(der)
+}
+
+func parsePKCS8User(der []byte) (*User, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("blockchain: not an RSA private key")
+	}
+	return &User{PrivateKey: rsaKey}, nil
+}
+
+func passphraseCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	users := testUsers()
+	a, b := users[0], users[1]
+	if a.Address() != a.Address() {
+		t.Fatal("address of the same key changed")
+	}
+	if a.Address() != AddressFromPublicKey(&a.PrivateKey.PublicKey) {
+		t.Fatal("Address and AddressFromPublicKey differ")
+	}
+	if a.Address() == b.Address() {
+		t.Fatal("different keys share an address")
+	}
+	if len(a.Address()) != 64 {
+		t.Fatalf("address %q isn't a hex SHA-256", a.Address())
+	}
+}
+
+// checkSameUser fails unless loaded has the address of user and verifies its signatures.
+func checkSameUser(t *testing.T, user, loaded *User) {
+	t.Helper()
+	if loaded.Address() != user.Address() {
+		t.Fatalf("loaded address %s, want %s", loaded.Address(), user.Address())
+	}
+	tx := &Transaction{RandBytes: []byte{1}, Receiver: "receiver", Value: 1}
+	if err := user.SignTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyTransaction(tx, &loaded.PrivateKey.PublicKey) {
+		t.Fatal("loaded key doesn't verify a signature of the original")
+	}
+}
+
+func TestExportPEM(t *testing.T) {
+	user := testUsers()[0]
+	data, err := user.ExportPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadUser(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameUser(t, user, loaded)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(user.PrivateKey)})
+	if loaded, err = LoadUser(pkcs1); err != nil {
+		t.Fatal(err)
+	}
+	checkSameUser(t, user, loaded)
+}
+
+func TestExportEncryptedPEM(t *testing.T) {
+	user := testUsers()[0]
+	data, err := user.ExportEncryptedPEM("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadEncryptedUser(data, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameUser(t, user, loaded)
+	if _, err := LoadEncryptedUser(data, "wrong"); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("wrong passphrase: got %v, want ErrPassphrase", err)
+	}
+	if _, err := LoadUser(data); err == nil {
+		t.Fatal("LoadUser decoded an encrypted key")
+	}
+}
+
+func TestNewUser(t *testing.T) {
+	users := testUsers()
+	for _, user := range users[:2] {
+		if user.PrivateKey == nil {
+			t.Fatal("NewUser made a user without a key")
+		}
+		if bits := user.PrivateKey.N.BitLen(); bits != KeySize {
+			t.Fatalf("key of %d bits, want %d", bits, KeySize)
+		}
+	}
+	if users[0].PrivateKey.Equal(users[1].PrivateKey) {
+		t.Fatal("two NewUser calls made the same key")
+	}
+}
+
+func TestNewUserWithBits(t *testing.T) {
+	if _, err := NewUserWithBits(1024); err == nil {
+		t.Fatal("NewUserWithBits accepted a 1024 bit key")
+	}
+	if testing.Short() {
+		t.Skip("3072 bit keys are slow to make")
+	}
+	user, err := NewUserWithBits(3072)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits := user.PrivateKey.N.BitLen(); bits != 3072 {
+		t.Fatalf("key of %d bits, want 3072", bits)
+	}
+}
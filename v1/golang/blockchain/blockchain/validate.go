@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// InvalidBlockError reports the first block that failed chain validation.
+type InvalidBlockError struct {
+	Index  uint64
+	Reason string
+}
+
+func (e *InvalidBlockError) Error() string {
+	return fmt.Sprintf("blockchain: block %d is invalid: %s", e.Index, e.Reason)
+}
+
+// IsValid walks the chain from genesis and checks hash links, hashes, difficulties,
+// proofs of work, miner and transaction signatures. The error is an *InvalidBlockError for the first bad block.
+func (chain *BlockChain) IsValid() (bool, error) {
+	height, err := chain.store.Height()
+	if err != nil {
+		return false, err
+	}
+	var prev *Block
+	for index := uint64(0); index < height; index++ {
+		block, err := chain.store.GetBlock(index)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return false, &InvalidBlockError{Index: index, Reason: "missing"}
+		case errors.Is(err, ErrDeserialize):
+			return false, &InvalidBlockError{Index: index, Reason: "can't deserialize"}
+		case err != nil:
+			return false, err
+		}
+		if reason := validateBlock(block, prev); reason != "" {
+			return false, &InvalidBlockError{Index: index, Reason: reason}
+		}
+		if index > 0 {
+			err := chain.checkDifficulty(block, index-1, nil)
+			if errors.Is(err, ErrDifficulty) {
+				return false, &InvalidBlockError{Index: index, Reason: err.Error()}
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		prev = block
+	}
+	return true, nil
+}
+
+// validateBlock returns why block can't follow prev, or "" when it can.
+// A nil prev means block must be the genesis block: its CurrHash is its hash,
+// or GenesisBlock for legacy ones without a Genesis config.
+func validateBlock(block, prev *Block) string {
+	if prev == nil {
+		legacy := block.Genesis == nil && bytes.Equal(block.CurrHash, []byte(GenesisBlock))
+		if len(block.PrevHash) != 0 || !legacy && !bytes.Equal(block.CurrHash, block.Hash()) {
+			return "bad genesis block"
+		}
+		return ""
+	}
+	if !bytes.Equal(block.PrevHash, prev.CurrHash) {
+		return "previous hash mismatch"
+	}
+	if !bytes.Equal(block.CurrHash, block.Hash()) {
+		return "hash mismatch"
+	}
+	if !block.IsValidProof() {
+		return "insufficient proof of work"
+	}
+	if !verifyMinerSignature(block) {
+		return "bad miner signature"
+	}
+	for i := range block.Transactions {
+		if !verifySignature(&block.Transactions[i]) {
+			return fmt.Sprintf("transaction %x has a bad signature", block.Transactions[i].CurrHash)
+		}
+	}
+	return ""
+}
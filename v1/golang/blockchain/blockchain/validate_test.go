@@ -0,0 +1,62 @@
+package blockchain
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// validChain is a sqlite chain of four blocks, the middle ones carrying a transfer.
+func validChain(t *testing.T) (*BlockChain, *sql.DB) {
+	t.Helper()
+	users := testUsers()
+	chain, _ := newSQLiteTestChain(t, users[0].Address())
+	mine(t, chain, users[1], *newTx(t, chain, users[0], users[2].Address(), 10))
+	mine(t, chain, users[1], *newTx(t, chain, users[0], users[2].Address(), 20))
+	mine(t, chain, users[1])
+	return chain, chain.store.(*SQLiteStore).DB
+}
+
+// tamper rewrites the stored block at index with change applied.
+func tamper(t *testing.T, chain *BlockChain, db *sql.DB, index uint64, change func(*Block)) {
+	t.Helper()
+	block, err := chain.GetBlock(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(block)
+	if _, err := db.Exec("update block_chain set block = ? where id = ?", SerializeBlock(block), index); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	chain, _ := validChain(t)
+	if ok, err := chain.IsValid(); !ok || err != nil {
+		t.Fatalf("clean chain: got %v, %v", ok, err)
+	}
+}
+
+func TestIsValidTampered(t *testing.T) {
+	tests := map[string]func(*Block){
+		"value":     func(b *Block) { b.Transactions[0].Value++ },
+		"balance":   func(b *Block) { b.Mapping[StorageChain]++ },
+		"nonce":     func(b *Block) { b.Nonce++ },
+		"prev hash": func(b *Block) { b.PrevHash = b.CurrHash },
+		"signature": func(b *Block) { b.Transactions[0].Signature[0] ^= 1 },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			chain, db := validChain(t)
+			tamper(t, chain, db, 2, change)
+			ok, err := chain.IsValid()
+			var invalid *InvalidBlockError
+			if ok || !errors.As(err, &invalid) {
+				t.Fatalf("got %v, %v, want an InvalidBlockError", ok, err)
+			}
+			if invalid.Index != 2 {
+				t.Fatalf("block %d reported invalid, want 2: %v", invalid.Index, err)
+			}
+		})
+	}
+}
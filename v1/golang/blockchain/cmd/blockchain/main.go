@@ -0,0 +1,186 @@
+// Command blockchain creates, inspects and syncs chains stored in sqlite files.
+//
+//	blockchain init <file> <receiver>     create file with a genesis block crediting receiver
+//	blockchain print <file>               print every block and its transactions
+//	blockchain balance <file> <address>   print the balance of address
+//	blockchain sync [-dnsseed seeds] [-port port] <file> [peer...]
+//	                                      download the blocks of the peers, and of the
+//	                                      nodes the comma separated DNS seeds resolve
+//	                                      to, into file, created when missing
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"blockchain/blockchain"
+	"blockchain/network"
+)
+
+const usage = `usage:
+	blockchain init <file> <receiver>
+	blockchain print <file>
+	blockchain balance <file> <address>
+	blockchain sync [-dnsseed seeds] [-port port] <file> [peer...]`
+
+// defaultPort is the port of the nodes DNS seeds resolve to.
+const defaultPort = "8080"
+
+var errUsage = errors.New("bad usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "blockchain:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "init" && len(args) == 2:
+		return initChain(out, args[0], args[1])
+	case cmd == "print" && len(args) == 1:
+		return printChain(out, args[0])
+	case cmd == "balance" && len(args) == 2:
+		return printBalance(out, args[0], args[1])
+	case cmd == "sync":
+		return syncChain(out, args)
+	}
+	return errUsage
+}
+
+func initChain(out io.Writer, file, receiver string) error {
+	// NewChain truncates an existing file
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("%s already exists", file)
+	}
+	if err := blockchain.NewChain(file, receiver); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created %s, genesis credits %s\n", file, receiver)
+	return nil
+}
+
+func printChain(out io.Writer, file string) error {
+	chain, err := blockchain.LoadChain(file)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	height, err := chain.Height()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < height; i++ {
+		block, err := chain.GetBlock(i)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", i, err)
+		}
+		fmt.Fprintf(out, "block %d %x\n", i, block.CurrHash)
+		fmt.Fprintf(out, "\tprev       %x\n", block.PrevHash)
+		fmt.Fprintf(out, "\tminer      %s\n", block.Miner)
+		fmt.Fprintf(out, "\tdifficulty %d\n", block.Difficulty)
+		fmt.Fprintf(out, "\ttime       %s\n", block.Timestamp.UTC().Format(time.RFC3339))
+		if g := block.Genesis; g != nil {
+			fmt.Fprintf(out, "\tgenesis    reward %d storage %d block time %s\n", g.GenesisReward, g.StorageValue, g.TargetBlockTime)
+		}
+		for _, tx := range block.Transactions {
+			fmt.Fprintf(out, "\ttx %x %s -> %s value %d fee %d\n", tx.CurrHash, tx.Sender, tx.Receiver, tx.Value, tx.ToStorage)
+		}
+		addresses := make([]string, 0, len(block.Mapping))
+		for address := range block.Mapping {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		for _, address := range addresses {
+			fmt.Fprintf(out, "\tbalance %s %d\n", address, block.Mapping[address])
+		}
+	}
+	return nil
+}
+
+func printBalance(out io.Writer, file, address string) error {
+	chain, err := blockchain.LoadChain(file)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	balance, err := chain.Balance(address)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, balance)
+	return nil
+}
+
+func syncChain(out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	seeds := flags.String("dnsseed", "", "comma separated DNS seed hostnames")
+	port := flags.String("port", defaultPort, "port of the nodes the seeds resolve to")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	file, peers := flags.Arg(0), flags.Args()[1:]
+	if *seeds == "" && len(peers) == 0 {
+		return errUsage
+	}
+	pm := network.NewPeerManager()
+	for _, peer := range peers {
+		pm.Add(peer)
+	}
+	if *seeds != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), network.WaitTime*time.Second)
+		defer cancel()
+		if err := pm.AddSeeds(ctx, strings.Split(*seeds, ","), *port); err != nil {
+			return err
+		}
+	}
+	chain, err := openOrCreate(file)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	if err := chain.SyncFromPeers(pm); err != nil {
+		return err
+	}
+	height, err := chain.Height()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "synced %s to height %d\n", file, height)
+	return nil
+}
+
+// openOrCreate loads the chain in file, a missing file is created empty so the sync
+// starts with the genesis block of the peer.
+func openOrCreate(file string) (*blockchain.BlockChain, error) {
+	if _, err := os.Stat(file); err == nil {
+		return blockchain.LoadChain(file)
+	}
+	store, err := blockchain.CreateSQLiteStore(file)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := blockchain.OpenChain(store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return chain, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blockchain/blockchain"
+	"blockchain/network"
+)
+
+// serveChain serves a chain of a genesis and one mined block on a local port.
+func serveChain(t *testing.T) (*blockchain.BlockChain, string) {
+	t.Helper()
+	miner, err := blockchain.NewUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := blockchain.NewMemoryChain(miner.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := chain.MineBlock(miner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	mux := network.NewMux()
+	chain.Register(mux)
+	l, err := network.Listen("127.0.0.1:0", mux.ServeConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return chain, l.Addr().String()
+}
+
+func TestSyncDNSSeed(t *testing.T) {
+	remote, address := serveChain(t)
+	_, port, _ := net.SplitHostPort(address)
+	file := filepath.Join(t.TempDir(), "chain.db")
+	var out bytes.Buffer
+	if err := run([]string{"sync", "-dnsseed", "127.0.0.1", "-port", port, file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "synced "+file+" to height 2\n" {
+		t.Fatalf("got %q", out.String())
+	}
+	chain, err := blockchain.LoadChain(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	local, _ := chain.LastBlock()
+	tip, _ := remote.LastBlock()
+	if !bytes.Equal(local.CurrHash, tip.CurrHash) {
+		t.Fatalf("tip %x, want %x", local.CurrHash, tip.CurrHash)
+	}
+}
+
+func TestSyncPeer(t *testing.T) {
+	_, address := serveChain(t)
+	file := filepath.Join(t.TempDir(), "chain.db")
+	var out bytes.Buffer
+	if err := run([]string{"sync", file, address}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "to height 2\n") {
+		t.Fatalf("got %q", out.String())
+	}
+}
+
+func TestSyncUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"sync"},
+		{"sync", "chain.db"},
+		{"sync", "-dnsseed"},
+		{"sync", "-unknown", "x", "chain.db"},
+	} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("%q: got %v, want errUsage", args, err)
+		}
+	}
+}
+
+func TestInitBalance(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chain.db")
+	var out bytes.Buffer
+	if err := run([]string{"init", file, "alice"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"init", file, "alice"}, &out); err == nil {
+		t.Fatal("init overwrote an existing chain")
+	}
+	out.Reset()
+	if err := run([]string{"balance", file, "alice"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "100\n" {
+		t.Fatalf("balance %q, want 100", out.String())
+	}
+	out.Reset()
+	if err := run([]string{"print", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "balance alice 100") {
+		t.Fatalf("print doesn't show the genesis balance:\n%s", out.String())
+	}
+}
+
+func TestPrint(t *testing.T) {
+	remote, address := serveChain(t)
+	file := filepath.Join(t.TempDir(), "chain.db")
+	if err := run([]string{"sync", file, address}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{"print", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	tip, _ := remote.LastBlock()
+	for _, want := range []string{
+		"block 0 ",
+		fmt.Sprintf("block 1 %x\n", tip.CurrHash),
+		"\tminer      " + tip.Miner + "\n",
+		"\tgenesis    reward 100 storage 100",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("print output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	for _, args := range [][]string{
+		{},
+		{"init", "chain.db"},
+		{"print"},
+		{"balance", "chain.db"},
+		{"unknown", "chain.db"},
+	} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("%q: got %v, want errUsage", args, err)
+		}
+	}
+	for _, args := range [][]string{{"print", missing}, {"balance", missing, "alice"}} {
+		if err := run(args, &bytes.Buffer{}); err == nil || errors.Is(err, errUsage) {
+			t.Errorf("%q: got %v, want an error about the file", args, err)
+		}
+	}
+}
@@ -1,3 +1,9 @@
 module blockchain
 
 go 1.21.6
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.21.0
+	golang.org/x/net v0.22.0
+)
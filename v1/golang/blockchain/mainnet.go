@@ -2,13 +2,13 @@ package main
 
 import (
 	"blockchain/network"
+	"context"
 	"fmt"
 	"strings"
-	"time"
 )
 
 const (
-	ToUpper = iota + 1
+	ToUpper network.Option = iota + 1
 	ToLower
 )
 const (
@@ -16,26 +16,33 @@ const (
 )
 
 func main() {
-	go network.Listen(Address, handleServer)
-
-	time.Sleep(500 * time.Millisecond)
-
-	res := network.Send(Address, &network.Package{Option: ToUpper, Data: "Hello, World!"})
+	mux := network.NewMux()
+	mux.HandleFunc(ToUpper, handleToUpper)
+	mux.HandleFunc(ToLower, handleToLower)
+	if _, err := network.Listen(Address, mux.ServeConn); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	res, err := network.Send(Address, &network.Package{Option: ToUpper, Data: "Hello, World!"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println(res.Data)
 
-	res = network.Send(Address, &network.Package{Option: ToLower, Data: "Hello, World!"})
+	res, err = network.Send(Address, &network.Package{Option: ToLower, Data: "Hello, World!"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println(res.Data)
 }
 
-func handleServer(conn network.Conn, pack *network.Package) {
-	network.Handle(ToUpper, conn, pack, handleToUpper)
-	network.Handle(ToLower, conn, pack, handleToLower)
-}
-
-func handleToLower(p *network.Package) string {
-	return strings.ToLower(p.Data)
+func handleToLower(_ context.Context, p *network.Package) (string, error) {
+	return strings.ToLower(p.Data), nil
 }
 
-func handleToUpper(p *network.Package) string {
-	return strings.ToUpper(p.Data)
+func handleToUpper(_ context.Context, p *network.Package) (string, error) {
+	return strings.ToUpper(p.Data), nil
 }
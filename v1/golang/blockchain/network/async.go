@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+	"sync"
+)
+
+// AsyncWorkers and AsyncQueue size the sender behind SendAsync, make an AsyncSender
+// with NewAsyncSender for other sizes.
+const (
+	AsyncWorkers = 16
+	AsyncQueue   = 1024
+)
+
+var (
+	ErrQueueFull    = errors.New("network: send queue full")
+	ErrSenderClosed = errors.New("network: sender closed")
+)
+
+// AsyncSender runs sends on a fixed number of workers fed by a bounded queue.
+type AsyncSender struct {
+	jobs chan asyncJob
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type asyncJob struct {
+	address string
+	pack    *Package
+	res     chan Result
+}
+
+// NewAsyncSender starts workers goroutines sending from a queue of up to queue packages.
+func NewAsyncSender(workers, queue int) *AsyncSender {
+	s := &AsyncSender{jobs: make(chan asyncJob, max(queue, 0))}
+	for i := 0; i < max(workers, 1); i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+func (s *AsyncSender) work() {
+	defer s.wg.Done()
+	for job := range s.jobs {
+		res, err := Send(job.address, job.pack)
+		job.res <- Result{res, err}
+	}
+}
+
+// Send queues pack for address and returns at once, the channel receives the Result.
+// When the queue is full or the sender closed the Result carries ErrQueueFull or
+// ErrSenderClosed right away.
+func (s *AsyncSender) Send(address string, pack *Package) <-chan Result {
+	res := make(chan Result, 1)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		res <- Result{Err: ErrSenderClosed}
+		return res
+	}
+	select {
+	case s.jobs <- asyncJob{address, pack, res}:
+	default:
+		res <- Result{Err: ErrQueueFull}
+	}
+	return res
+}
+
+// Close stops accepting packages and waits for the queued ones to be sent.
+func (s *AsyncSender) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.jobs)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+var (
+	asyncOnce   sync.Once
+	asyncSender *AsyncSender
+)
+
+// SendAsync is Send without waiting, on a shared AsyncSender of AsyncWorkers
+// workers and an AsyncQueue long queue.
+func SendAsync(address string, pack *Package) <-chan Result {
+	asyncOnce.Do(func() { asyncSender = NewAsyncSender(AsyncWorkers, AsyncQueue) })
+	return asyncSender.Send(address, pack)
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAsyncSenderWorkers(t *testing.T) {
+	var c concurrency
+	_, address := listen(t, c.handle)
+	s := NewAsyncSender(3, 100)
+	results := make([]<-chan Result, 30)
+	for i := range results {
+		results[i] = s.Send(address, &Package{Option: 1, Data: string(rune('a' + i))})
+	}
+	// collected in reverse, each result arrives on its own channel whatever the order
+	for i := len(results) - 1; i >= 0; i-- {
+		r := <-results[i]
+		if r.Err != nil || r.Package.Data != string(rune('a'+i)) {
+			t.Fatalf("send %d: %+v", i, r)
+		}
+	}
+	s.Close()
+	if c.peak > 3 {
+		t.Fatalf("%d concurrent sends on 3 workers", c.peak)
+	}
+}
+
+func TestAsyncSenderQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		<-release
+		echo(conn, pack)
+	})
+	s := NewAsyncSender(1, 1)
+	first := s.Send(address, &Package{Option: 1})
+	// wait for the worker to take the first send off the queue
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.jobs) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	queued := s.Send(address, &Package{Option: 1})
+	select {
+	case r := <-s.Send(address, &Package{Option: 1}):
+		if !errors.Is(r.Err, ErrQueueFull) {
+			t.Fatalf("got %v, want ErrQueueFull", r.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked on a full queue")
+	}
+	close(release)
+	for _, res := range []<-chan Result{first, queued} {
+		if r := <-res; r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	s.Close()
+	if r := <-s.Send(address, &Package{Option: 1}); !errors.Is(r.Err, ErrSenderClosed) {
+		t.Fatalf("got %v, want ErrSenderClosed", r.Err)
+	}
+}
+
+func TestSendAsync(t *testing.T) {
+	_, address := listen(t, echo)
+	r := <-SendAsync(address, &Package{Option: 1, Data: "hello"})
+	if r.Err != nil || r.Package.Data != "hello" {
+		t.Fatalf("got %+v", r)
+	}
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var testKey = []byte("shared network key")
+
+func TestAuthKey(t *testing.T) {
+	_, address := listen(t, echo, AuthKey(testKey))
+	c, err := Dial(address, WithAuthKey(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Send(&Package{Option: 1, Data: "signed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "signed" {
+		t.Fatalf("got %q", res.Data)
+	}
+}
+
+func TestAuthKeyWrongKey(t *testing.T) {
+	dropped := make(chan error, 1)
+	var called atomic.Bool
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		called.Store(true)
+		echo(conn, pack)
+	}, AuthKey(testKey), OnConnError(func(_ net.Conn, err error) { dropped <- err }))
+	c, err := Dial(address, WithAuthKey([]byte("another key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send(&Package{Option: 1}); err == nil {
+		t.Fatal("request with a wrong key was answered")
+	}
+	if err := <-dropped; !errors.Is(err, ErrBadMAC) {
+		t.Fatalf("server error %v, want ErrBadMAC", err)
+	}
+	if called.Load() {
+		t.Fatal("handler ran for a forged frame")
+	}
+}
+
+func TestAuthKeyUnauthenticatedClient(t *testing.T) {
+	_, address := listen(t, echo, AuthKey(testKey))
+	_, err := Send(address, &Package{Option: 1})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || !strings.Contains(remote.Message, ErrUnauthenticated.Error()) {
+		t.Fatalf("got %v, want a remote ErrUnauthenticated", err)
+	}
+}
+
+func TestFrameMAC(t *testing.T) {
+	cfg := defaultConnConfig
+	cfg.key = testKey
+	buf := frame(CodecJSON, testKey, []byte("{}"))
+	if _, payload, err := readFrame(bytes.NewReader(buf), cfg); err != nil || string(payload) != "{}" {
+		t.Fatalf("got %q, %v", payload, err)
+	}
+	buf[HeaderSize] ^= 1 // fix the checksum so only the MAC can tell
+	tampered := frame(CodecJSON, nil, buf[HeaderSize:HeaderSize+2])
+	copy(buf[11:HeaderSize], tampered[11:HeaderSize])
+	if _, _, err := readFrame(bytes.NewReader(buf), cfg); !errors.Is(err, ErrBadMAC) {
+		t.Fatalf("tampered payload: got %v, want ErrBadMAC", err)
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Allow restricts the listener to remote IPs inside one of cidrs, e.g. "10.0.0.0/8"
+// or "::1/128". Other connections are closed right after Accept.
+func Allow(cidrs ...string) ListenOption {
+	return func(l *Listener) { l.allow = append(l.allow, cidrs...) }
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: Allow: %w", ErrInvalidOption, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// canonicalIP is the form IPs are compared and keyed in: brackets dropped and
+// IPv4-mapped IPv6 addresses unmapped, so "[::ffff:1.2.3.4]" is "1.2.3.4".
+// Strings that aren't an IP are returned unchanged.
+func canonicalIP(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]"))
+	if err != nil {
+		return ip
+	}
+	return addr.Unmap().String()
+}
+
+// Ban refuses connections from ip for d and closes the current ones.
+func (l *Listener) Ban(ip string, d time.Duration) {
+	ip = canonicalIP(ip)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bans[ip] = time.Now().Add(d)
+	for conn := range l.conns {
+		if remoteIP(conn) == ip {
+			conn.Close()
+		}
+	}
+}
+
+func (l *Listener) Unban(ip string) {
+	ip = canonicalIP(ip)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.bans, ip)
+}
+
+// Bans returns the banned IPs, in canonical form, with the time their ban expires.
+func (l *Listener) Bans() map[string]time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expireBans()
+	bans := make(map[string]time.Time, len(l.bans))
+	for ip, until := range l.bans {
+		bans[ip] = until
+	}
+	return bans
+}
+
+// refused reports whether ip is banned or outside the allowlist, l.mu must be held.
+func (l *Listener) refused(ip string) bool {
+	ip = canonicalIP(ip)
+	l.expireBans()
+	if _, ok := l.bans[ip]; ok {
+		return true
+	}
+	if len(l.allowed) == 0 {
+		return false
+	}
+	addr := net.ParseIP(ip)
+	for _, n := range l.allowed {
+		if addr != nil && n.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Listener) expireBans() {
+	now := time.Now()
+	for ip, until := range l.bans {
+		if now.After(until) {
+			delete(l.bans, ip)
+		}
+	}
+}
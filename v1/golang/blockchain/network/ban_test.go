@@ -0,0 +1,120 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBan(t *testing.T) {
+	l, address := listen(t, echo)
+	l.Ban("127.0.0.1", time.Minute)
+	if _, err := Send(address, &Package{Option: 1}); err == nil {
+		t.Fatal("banned IP got an answer")
+	}
+	if _, ok := l.Bans()["127.0.0.1"]; !ok {
+		t.Fatalf("bans %v miss 127.0.0.1", l.Bans())
+	}
+	l.Unban("127.0.0.1")
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatalf("after Unban: %v", err)
+	}
+}
+
+func TestBanIPForms(t *testing.T) {
+	for _, tt := range []struct{ ip, want string }{
+		{"1.2.3.4", "1.2.3.4"},
+		{"::ffff:1.2.3.4", "1.2.3.4"},
+		{"[::ffff:1.2.3.4]", "1.2.3.4"},
+		{"[::1]", "::1"},
+		{"0:0:0:0:0:0:0:1", "::1"},
+		{"not an ip", "not an ip"},
+	} {
+		if got := canonicalIP(tt.ip); got != tt.want {
+			t.Errorf("canonicalIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	l, address := listen(t, echo)
+	l.Ban("::ffff:127.0.0.1", time.Minute)
+	if _, err := Send(address, &Package{Option: 1}); err == nil {
+		t.Fatal("IP banned in its IPv4-mapped form got an answer")
+	}
+	if _, ok := l.Bans()["127.0.0.1"]; !ok {
+		t.Fatalf("bans %v miss 127.0.0.1", l.Bans())
+	}
+	l.Unban("[::ffff:127.0.0.1]")
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatalf("after Unban: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.allowed, _ = parseCIDRs([]string{"127.0.0.0/8"})
+	if l.refused("::ffff:127.0.0.1") || l.refused("[::ffff:127.0.0.2]") || !l.refused("::ffff:10.0.0.1") {
+		t.Fatal("IPv4-mapped addresses not matched against an IPv4 allowlist")
+	}
+}
+
+func TestBanExpires(t *testing.T) {
+	l, address := listen(t, echo)
+	l.Ban("127.0.0.1", 50*time.Millisecond)
+	if _, err := Send(address, &Package{Option: 1}); err == nil {
+		t.Fatal("banned IP got an answer")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatalf("after the ban expired: %v", err)
+	}
+	if bans := l.Bans(); len(bans) != 0 {
+		t.Fatalf("expired bans listed: %v", bans)
+	}
+}
+
+func TestBanClosesConnections(t *testing.T) {
+	l, address := listen(t, echo)
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send(&Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+	l.Ban("127.0.0.1", time.Minute)
+	waitFor(t, func() bool { return c.State() == StateClosed })
+}
+
+func TestAllow(t *testing.T) {
+	_, refused := listen(t, echo, Allow("10.0.0.0/8", "::1/128"))
+	if _, err := Send(refused, &Package{Option: 1}); err == nil {
+		t.Fatal("IP outside the allowlist got an answer")
+	}
+	_, allowed := listen(t, echo, Allow("10.0.0.0/8", "127.0.0.0/8"))
+	if _, err := Send(allowed, &Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllowMatches(t *testing.T) {
+	nets, err := parseCIDRs([]string{"192.168.1.0/24", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Listener{allowed: nets, bans: make(map[string]time.Time)}
+	for ip, want := range map[string]bool{
+		"192.168.1.7":     false,
+		"192.168.2.7":     true,
+		"2001:db8::1":     false,
+		"2001:db9::1":     true,
+		"::ffff:c0a8:101": false, // 192.168.1.1 mapped to IPv6
+		"garbage":         true,
+	} {
+		if got := l.refused(ip); got != want {
+			t.Errorf("refused(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if _, err := Listen("127.0.0.1:0", echo, Allow("10.0.0.0")); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("bad CIDR: got %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BroadcastWorkers limits how many peers Broadcast dials at once, see Workers.
+const BroadcastWorkers = 16
+
+// Result of a request to one peer, Err is set when no response arrived.
+type Result struct {
+	Package *Package
+	Err     error
+}
+
+// BroadcastOption configures Broadcast.
+type BroadcastOption func(*broadcastConfig)
+
+type broadcastConfig struct {
+	workers     int
+	slots       sendSlots
+	peerTimeout time.Duration
+	timeout     time.Duration
+}
+
+// Workers overrides BroadcastWorkers for one Broadcast.
+func Workers(n int) BroadcastOption {
+	return func(cfg *broadcastConfig) { cfg.workers = n }
+}
+
+// MaxConcurrentSends makes one Broadcast open at most n connections at once on
+// its own, instead of sharing the limit of Send, see SetMaxConcurrentSends. 0 means
+// no limit.
+func MaxConcurrentSends(n int) BroadcastOption {
+	return func(cfg *broadcastConfig) { cfg.slots = newSendSlots(n) }
+}
+
+// PeerTimeout bounds each peer request, WaitTime seconds by default.
+func PeerTimeout(d time.Duration) BroadcastOption {
+	return func(cfg *broadcastConfig) { cfg.peerTimeout = d }
+}
+
+// OverallTimeout bounds the whole Broadcast, peers not answered by then fail with ErrTimeout.
+func OverallTimeout(d time.Duration) BroadcastOption {
+	return func(cfg *broadcastConfig) { cfg.timeout = d }
+}
+
+// Broadcast sends pack to every address concurrently and collects a Result per address.
+// It shares the limit of Send on open connections, see SetMaxConcurrentSends, unless
+// MaxConcurrentSends is given; a peer waiting for its turn past PeerTimeout fails
+// with ErrTimeout.
+func Broadcast(addresses []string, pack *Package, opts ...BroadcastOption) map[string]Result {
+	cfg := broadcastConfig{workers: BroadcastWorkers, slots: sharedSendSlots(), peerTimeout: WaitTime * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ctx := context.Background()
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	var (
+		res  = make(map[string]Result, len(addresses))
+		jobs = make(chan string)
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+	store := func(address string, r Result) {
+		mu.Lock()
+		res[address] = r
+		mu.Unlock()
+	}
+	workers := min(max(cfg.workers, 1), len(addresses))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range jobs {
+				peerCtx, cancel := context.WithTimeout(ctx, cfg.peerTimeout)
+				resp, err := send(peerCtx, cfg.slots, DefaultDialer.DialContext, address, pack)
+				cancel()
+				store(address, Result{resp, err})
+			}
+		}()
+	}
+feed:
+	for i, address := range addresses {
+		select {
+		case jobs <- address:
+		case <-ctx.Done():
+			// overall deadline passed before these peers got a worker
+			for _, address := range addresses[i:] {
+				store(address, Result{Err: contextError(ctx, address)})
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return res
+}
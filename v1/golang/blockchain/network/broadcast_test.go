@@ -0,0 +1,197 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrency counts the handlers running at once and remembers the peak.
+type concurrency struct {
+	mu           sync.Mutex
+	active, peak int
+}
+
+func (c *concurrency) handle(conn Conn, pack *Package) {
+	c.mu.Lock()
+	c.active++
+	c.peak = max(c.peak, c.active)
+	c.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	echo(conn, pack)
+}
+
+func TestBroadcastMaxConcurrentSends(t *testing.T) {
+	var c concurrency
+	addresses := make([]string, 100)
+	for i := range addresses {
+		_, addresses[i] = listen(t, c.handle)
+	}
+	res := Broadcast(addresses, &Package{Option: 1, Data: "block"}, Workers(50), MaxConcurrentSends(10))
+	if len(res) != len(addresses) {
+		t.Fatalf("%d results, want %d", len(res), len(addresses))
+	}
+	for address, r := range res {
+		if r.Err != nil || r.Package.Data != "block" {
+			t.Fatalf("%s: %+v", address, r)
+		}
+	}
+	if c.peak > 10 {
+		t.Fatalf("%d concurrent sends, limit 10", c.peak)
+	}
+}
+
+// holdSends starts n Sends to a listener that answers them once the test ends, and
+// waits until all of them are being handled.
+func holdSends(t *testing.T, n int) (address string, handled *atomic.Int32) {
+	handled = new(atomic.Int32)
+	release := make(chan struct{})
+	_, address = listen(t, func(conn Conn, pack *Package) {
+		handled.Add(1)
+		<-release
+		echo(conn, pack)
+	})
+	done := make(chan error, n)
+	t.Cleanup(func() {
+		close(release)
+		for i := 0; i < n; i++ {
+			if err := <-done; err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := Send(address, &Package{Option: 1})
+			done <- err
+		}()
+	}
+	waitFor(t, func() bool { return handled.Load() == int32(n) })
+	return address, handled
+}
+
+func TestSendLimit(t *testing.T) {
+	address, handled := holdSends(t, DefaultMaxConcurrentSends)
+	_, err := SendWithTimeout(address, &Package{Option: 1}, 100*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("send %d: got %v, want ErrTimeout", DefaultMaxConcurrentSends+1, err)
+	}
+	if got := handled.Load(); got != DefaultMaxConcurrentSends {
+		t.Fatalf("%d sends reached the listener, limit %d", got, DefaultMaxConcurrentSends)
+	}
+}
+
+func TestSetMaxConcurrentSends(t *testing.T) {
+	saved := sendSlotsSet.Load()
+	t.Cleanup(func() { sendSlotsSet.Store(saved) })
+	SetMaxConcurrentSends(2)
+	address, _ := holdSends(t, 2)
+	if _, err := SendWithTimeout(address, &Package{Option: 1}, 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("third send: got %v, want ErrTimeout", err)
+	}
+	_, good := listen(t, echo)
+	if res := Broadcast([]string{good}, &Package{Option: 1}, PeerTimeout(100*time.Millisecond)); !errors.Is(res[good].Err, ErrTimeout) {
+		t.Fatalf("Broadcast: got %v, want ErrTimeout as it shares the limit", res[good].Err)
+	}
+	// the held sends keep their slots, the new limit applies to the next ones
+	SetMaxConcurrentSends(0)
+	if _, err := SendWithTimeout(good, &Package{Option: 1}, time.Second); err != nil {
+		t.Fatalf("no limit: %v", err)
+	}
+}
+
+func TestBroadcastHangingPeer(t *testing.T) {
+	_, hanging := listenRaw(t, func(conn net.Conn) {
+		// read the request, never answer
+		conn.Read(make([]byte, 1024))
+		time.Sleep(time.Second)
+	})
+	_, good := listen(t, echo)
+	start := time.Now()
+	res := Broadcast([]string{hanging, good}, &Package{Option: 1}, PeerTimeout(50*time.Millisecond), Workers(1))
+	if !errors.Is(res[hanging].Err, ErrTimeout) {
+		t.Fatalf("hanging peer: got %v, want ErrTimeout", res[hanging].Err)
+	}
+	if res[good].Err != nil {
+		t.Fatalf("good peer: %v", res[good].Err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("the hanging peer held its worker past PeerTimeout")
+	}
+}
+
+func TestBroadcastOverallTimeout(t *testing.T) {
+	_, hanging := listenRaw(t, func(conn net.Conn) { time.Sleep(time.Second) })
+	res := Broadcast([]string{hanging}, &Package{Option: 1}, OverallTimeout(20*time.Millisecond))
+	if !errors.Is(res[hanging].Err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", res[hanging].Err)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	var addresses []string
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprint("node", i)
+		_, address := listen(t, func(conn Conn, pack *Package) {
+			conn.WritePackage(&Package{Option: pack.Option, Data: name + " got " + pack.Data})
+		})
+		addresses = append(addresses, address)
+	}
+	res := Broadcast(addresses, &Package{Option: 1, Data: "block"})
+	if len(res) != len(addresses) {
+		t.Fatalf("got %d results, want %d", len(res), len(addresses))
+	}
+	for i, address := range addresses {
+		r := res[address]
+		if r.Err != nil {
+			t.Fatalf("%s: %v", address, r.Err)
+		}
+		if want := fmt.Sprint("node", i, " got block"); r.Package.Data != want {
+			t.Fatalf("%s: got %q, want %q", address, r.Package.Data, want)
+		}
+	}
+}
+
+func TestBroadcastMixedPeers(t *testing.T) {
+	done := make(chan struct{})
+	sleepy := func(d time.Duration) func(Conn, *Package) {
+		return func(conn Conn, pack *Package) {
+			select {
+			case <-time.After(d):
+				echo(conn, pack)
+			case <-done:
+			}
+		}
+	}
+	_, fast1 := listen(t, echo)
+	_, fast2 := listen(t, echo)
+	_, slow := listen(t, sleepy(30*time.Millisecond))
+	_, stuck := listen(t, sleepy(time.Minute))
+	t.Cleanup(func() { close(done) }) // before the listeners wait for their handlers
+	unreachable := freeAddress(t)
+
+	start := time.Now()
+	res := Broadcast([]string{fast1, fast2, slow, stuck, unreachable}, &Package{Option: 1, Data: "tx"},
+		PeerTimeout(200*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Broadcast took %v", elapsed)
+	}
+	for _, address := range []string{fast1, fast2, slow} {
+		if r := res[address]; r.Err != nil || r.Package.Data != "tx" {
+			t.Fatalf("%s: got %+v", address, r)
+		}
+	}
+	if err := res[stuck].Err; !errors.Is(err, ErrTimeout) {
+		t.Fatalf("stuck peer: got %v, want ErrTimeout", err)
+	}
+	if err := res[unreachable].Err; !errors.Is(err, ErrDial) {
+		t.Fatalf("unreachable peer: got %v, want ErrDial", err)
+	}
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Packages whose encoding exceeds the chunk size of the connection are sent as a
+// chunked transfer: the encoded package is split into OptionChunk packages carrying
+// Chunk, Chunks and a part in Raw, all with the ID of the original package. The
+// receiver reassembles and decodes them, so handlers and clients see a single package.
+// The reassembled package is capped by MaxPackageSize like a single frame.
+const (
+	OptionChunk Option = -3
+
+	ChunkSize       = 1 << 20  // 1MiB, the largest chunk, see connConfig.chunkSize
+	MaxTransferSize = 64 << 20 // 64MiB, the highest MaxPackageSize
+	TransferTimeout = 30 * time.Second
+)
+
+var (
+	ErrChunk            = errors.New("network: broken chunked transfer")
+	ErrTransferTooLarge = errors.New("network: transfer exceeds MaxPackageSize")
+)
+
+// chunkSize is the part of a transfer each chunk carries: half of maxSize, at most
+// ChunkSize, so a chunk frame with its part base64 encoded still fits in maxSize.
+func (cfg connConfig) chunkSize() int {
+	return min(ChunkSize, cfg.maxSize/2)
+}
+
+func writeChunked(conn net.Conn, cfg connConfig, id uint64, data []byte) error {
+	codec := cfg.codec
+	size := cfg.chunkSize()
+	chunks := (len(data) + size - 1) / size
+	for i := 0; i < chunks; i++ {
+		part := data[i*size : min((i+1)*size, len(data))]
+		chunk, err := codec.Marshal(&Package{ID: id, Option: OptionChunk, Chunk: i, Chunks: chunks, Raw: part})
+		if err != nil {
+			return err
+		}
+		if err := writeFull(conn, frame(codec.ID(), cfg.key, chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readChunked reads the chunks following first and decodes the reassembled package.
+// The whole transfer must arrive within TransferTimeout and fit in cfg.maxSize; every
+// chunk carries part of it, so there are at most cfg.maxSize chunks.
+func readChunked(conn *peerConn, cfg connConfig, first *Package) (*Package, int, error) {
+	if first.Chunk != 0 || first.Chunks < 1 || first.Chunks > cfg.maxSize || len(first.Raw) == 0 {
+		return nil, 0, fmt.Errorf("%w: chunk %d of %d", ErrChunk, first.Chunk, first.Chunks)
+	}
+	conn.SetReadDeadline(time.Now().Add(TransferTimeout))
+	data := first.Raw
+	for i := 1; i < first.Chunks; i++ {
+		chunk, _, err := readSingle(conn.reader(), cfg)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: chunk %d of %d: %w", ErrChunk, i, first.Chunks, err)
+		}
+		if chunk.Option != OptionChunk || chunk.ID != first.ID || chunk.Chunks != first.Chunks || chunk.Chunk != i {
+			return nil, 0, fmt.Errorf("%w: expected chunk %d of %d, got %d", ErrChunk, i, first.Chunks, chunk.Chunk)
+		}
+		if len(chunk.Raw) == 0 {
+			return nil, 0, fmt.Errorf("%w: empty chunk %d of %d", ErrChunk, i, first.Chunks)
+		}
+		if len(data)+len(chunk.Raw) > cfg.encodedSize() {
+			return nil, 0, fmt.Errorf("%w: over %d bytes", ErrTransferTooLarge, cfg.encodedSize())
+		}
+		data = append(data, chunk.Raw...)
+	}
+	pack, err := decode(cfg.codec, data)
+	return pack, len(data), err
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChunkedTransfer(t *testing.T) {
+	payload := make([]byte, 10<<20)
+	rand.New(rand.NewSource(1)).Read(payload)
+	_, address := listen(t, echo, MaxPackageSize(16<<20))
+	c, err := Dial(address, WithMaxPackageSize(16<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.SendContext(contextTimeout(t, time.Minute), NewBytesPackage(1, payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.Raw, payload) {
+		t.Fatalf("got %d bytes back, want the %d sent", len(res.Raw), len(payload))
+	}
+}
+
+func TestChunkedTransferTooLarge(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	sender := withConfig(client, connConfig{codec: JSONCodec, maxSize: 16 << 20, bufSize: BuffSize})
+	go func() {
+		sender.WritePackage(NewBytesPackage(1, make([]byte, 4<<20)))
+		client.Close()
+	}()
+	_, err := withConfig(server, defaultConnConfig).ReadPackage()
+	if !errors.Is(err, ErrTransferTooLarge) {
+		t.Fatalf("got %v, want ErrTransferTooLarge", err)
+	}
+}
+
+func TestChunkedTransferBroken(t *testing.T) {
+	chunk := func(i, n int) *Package {
+		return &Package{ID: 7, Option: OptionChunk, Chunk: i, Chunks: n, Raw: []byte("part")}
+	}
+	tests := []struct {
+		name   string
+		chunks []*Package
+	}{
+		{"out of order", []*Package{chunk(0, 3), chunk(2, 3), chunk(1, 3)}},
+		{"missing", []*Package{chunk(0, 3), chunk(1, 3)}},
+		{"first not 0", []*Package{chunk(1, 2)}},
+		{"other transfer", []*Package{chunk(0, 2), {ID: 8, Option: OptionChunk, Chunk: 1, Chunks: 2, Raw: []byte("x")}}},
+		{"empty", []*Package{chunk(0, 2), {ID: 7, Option: OptionChunk, Chunk: 1, Chunks: 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			go func() {
+				defer client.Close()
+				for _, pack := range tt.chunks {
+					data, _ := JSONCodec.Marshal(pack)
+					if writeFull(client, frame(JSONCodec.ID(), nil, data)) != nil {
+						return
+					}
+				}
+			}()
+			_, err := withConfig(server, defaultConnConfig).ReadPackage()
+			if !errors.Is(err, ErrChunk) {
+				t.Fatalf("got %v, want ErrChunk", err)
+			}
+		})
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	for _, tt := range []struct{ maxSize, want int }{
+		{MinPackageSize, MinPackageSize / 2},
+		{DMaxSize, ChunkSize},
+		{MaxTransferSize, ChunkSize},
+	} {
+		cfg := connConfig{maxSize: tt.maxSize}
+		if got := cfg.chunkSize(); got != tt.want {
+			t.Errorf("MaxPackageSize %d: chunk size %d, want %d", tt.maxSize, got, tt.want)
+		}
+	}
+}
+
+// Chunks of a small MaxPackageSize must fit its frames.
+func TestChunkedTransferSmallFrames(t *testing.T) {
+	_, address := listen(t, echo, MaxPackageSize(MinPackageSize))
+	c, err := Dial(address, WithMaxPackageSize(MinPackageSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	payload := bytes.Repeat([]byte{0xff}, MinPackageSize/2)
+	res, err := c.Send(NewBytesPackage(1, payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.Raw, payload) {
+		t.Fatal("payload changed in transit")
+	}
+}
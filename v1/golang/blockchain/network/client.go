@@ -0,0 +1,416 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrClientClosed = errors.New("network: client closed")
+
+// Client keeps one connection to a peer and multiplexes concurrent requests over it,
+// pairing each response with its request by Package.ID. Without WithReconnect the
+// client is closed when its connection fails.
+type Client struct {
+	address      string
+	dialer       Dialer
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	peer         connConfig
+	version      *Version
+	encrypt      bool
+	tls          *tls.Config
+	keepalive    time.Duration
+	maxMissed    int
+	reconnect    *RetryPolicy
+	queue        int
+	onState      func(State)
+	lastSeen     atomic.Int64  // UnixNano of the last package received
+	closed       chan struct{} // closed by Close
+
+	mu      sync.Mutex
+	conn    *peerConn
+	done    chan struct{} // closed when conn fails
+	remote  *Version
+	state   State
+	ready   chan struct{} // closed when StateConnecting ends
+	waiting int           // requests queued in StateConnecting
+	nextID  uint64
+	pending map[uint64]chan *Package
+	connErr error // why the last connection failed
+	err     error // why the client is closed
+}
+
+// State of a Client.
+type State int
+
+const (
+	StateConnecting State = iota
+	StateConnected
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// DialOption configures a Client.
+type DialOption func(*Client)
+
+// WithReadTimeout bounds how long the peer may stay silent while requests are in flight,
+// after that the connection is considered dead. 0 disables it.
+func WithReadTimeout(d time.Duration) DialOption {
+	return func(c *Client) { c.readTimeout = d }
+}
+
+// WithWriteTimeout bounds how long writing each request may take. 0 disables it.
+func WithWriteTimeout(d time.Duration) DialOption {
+	return func(c *Client) { c.writeTimeout = d }
+}
+
+// WithReconnect redials a failed connection, waiting per policy between attempts;
+// after MaxAttempts failed redials the client is closed, with 0 it redials until Close. While reconnecting, up to queue
+// requests wait for the new connection, further ones fail with ErrQueueFull.
+// Requests already sent on the failed connection fail, they may have been served.
+func WithReconnect(policy RetryPolicy, queue int) DialOption {
+	return func(c *Client) { c.reconnect, c.queue = &policy, queue }
+}
+
+// WithOnStateChange calls f with every new State of the client, from the goroutine
+// making the change.
+func WithOnStateChange(f func(State)) DialOption {
+	return func(c *Client) { c.onState = f }
+}
+
+// WithDialer opens the connection with d instead of DefaultDialer.
+func WithDialer(d Dialer) DialOption {
+	return func(c *Client) { c.dialer = d }
+}
+
+// WithCodec sets the codec packages are exchanged in, it must match the listener's.
+func WithCodec(codec Codec) DialOption {
+	return func(c *Client) { c.peer.codec = codec }
+}
+
+// WithAuthKey authenticates every frame with key and accepts only authenticated
+// responses, for listeners using AuthKey.
+func WithAuthKey(key []byte) DialOption {
+	return func(c *Client) { c.peer.key = key }
+}
+
+// WithMaxPackageSize caps the size of an incoming package, see MaxPackageSize.
+func WithMaxPackageSize(n int) DialOption {
+	return func(c *Client) { c.peer.maxSize = n }
+}
+
+// WithReadBufferSize is the size of the read buffer of the connection, BuffSize by default.
+func WithReadBufferSize(n int) DialOption {
+	return func(c *Client) { c.peer.bufSize = n }
+}
+
+// Dial opens a persistent connection to address.
+func Dial(address string, opts ...DialOption) (*Client, error) {
+	c := &Client{
+		address:      address,
+		dialer:       DefaultDialer,
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		peer:         defaultConnConfig,
+		closed:       make(chan struct{}),
+		pending:      make(map[uint64]chan *Package),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.peer.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect dials the peer and makes the connection current.
+func (c *Client) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+	defer cancel()
+	network, addr := splitAddress(c.address)
+	dialer := c.dialer
+	if c.tls != nil {
+		dialer = tlsDialer{dialer, c.tls}
+	}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		EventLog().Error("network: dial", "remote", c.address, "err", err)
+		return fmt.Errorf("%w: %s: %w", ErrDial, c.address, err)
+	}
+	peer := conn
+	if c.encrypt {
+		if peer, err = secure(conn, true); err != nil {
+			conn.Close()
+			return fmt.Errorf("%s: %w", c.address, err)
+		}
+	}
+	pc := withConfig(peer, c.peer)
+	var remote *Version
+	if c.version != nil {
+		if remote, err = dialHandshake(pc, c.version); err != nil {
+			conn.Close()
+			return fmt.Errorf("%s: %w", c.address, err)
+		}
+	}
+	c.mu.Lock()
+	if c.state == StateClosed {
+		c.mu.Unlock()
+		conn.Close()
+		return ErrClientClosed
+	}
+	done := make(chan struct{})
+	c.conn, c.done, c.remote = pc, done, remote
+	c.lastSeen.Store(time.Now().UnixNano())
+	c.setState(StateConnected)
+	c.mu.Unlock()
+	c.notify(StateConnected)
+	if c.peer.metrics != nil {
+		c.peer.metrics.ConnOpened()
+	}
+	go c.readLoop(pc)
+	if c.keepalive > 0 && c.maxMissed > 0 {
+		go c.keepaliveLoop(pc, done)
+	}
+	return nil
+}
+
+// redial reconnects per the WithReconnect policy until it succeeds or the client is closed.
+func (c *Client) redial() {
+	policy := c.reconnect
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(policy.delay(attempt)):
+		case <-c.closed:
+			return
+		}
+		err := c.connect()
+		if err == nil || errors.Is(err, ErrClientClosed) {
+			return
+		}
+		EventLog().Debug("network: reconnect", "remote", c.address, "attempt", attempt, "err", err)
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			c.shutdown(fmt.Errorf("network: reconnect to %s gave up after %d attempts: %w", c.address, attempt, err))
+			return
+		}
+	}
+}
+
+// RemoteVersion is the version the peer sent in the handshake, nil without WithHandshake.
+func (c *Client) RemoteVersion() *Version {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remote
+}
+
+// State of the client.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Send pack and wait WaitTime seconds for the response.
+func (c *Client) Send(pack *Package) (*Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+	defer cancel()
+	return c.SendContext(ctx, pack)
+}
+
+// SendContext pack and wait for the response until ctx is done.
+func (c *Client) SendContext(ctx context.Context, pack *Package) (*Package, error) {
+	ch := make(chan *Package, 1)
+	c.mu.Lock()
+	for c.state == StateConnecting {
+		if c.waiting >= c.queue {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("%w: reconnecting to %s", ErrQueueFull, c.address)
+		}
+		c.waiting++
+		ready := c.ready
+		c.mu.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+		}
+		c.mu.Lock()
+		c.waiting--
+		if ctx.Err() != nil {
+			c.mu.Unlock()
+			return nil, contextError(ctx, c.address)
+		}
+	}
+	if c.state == StateClosed {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	conn := c.conn
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	conn.SetReadDeadline(deadline(c.readTimeout))
+	c.mu.Unlock()
+
+	req := *pack
+	req.ID = id
+	conn.SetWriteDeadline(deadline(c.writeTimeout))
+	if err := conn.WritePackage(&req); err != nil {
+		// a partly written frame desyncs the stream for every request
+		err = fmt.Errorf("network: write to %s: %w", c.address, err)
+		c.fail(conn, err)
+		return nil, err
+	}
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return nil, c.connErr
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		if len(c.pending) == 0 {
+			conn.SetReadDeadline(time.Time{})
+		}
+		c.mu.Unlock()
+		return nil, contextError(ctx, c.address)
+	}
+}
+
+// Close the connection, requests in flight or queued fail with ErrClientClosed.
+func (c *Client) Close() error {
+	return c.shutdown(ErrClientClosed)
+}
+
+func (c *Client) readLoop(conn *peerConn) {
+	if c.peer.metrics != nil {
+		defer c.peer.metrics.ConnClosed()
+	}
+	for {
+		res, err := conn.ReadPackage()
+		if err != nil {
+			c.fail(conn, fmt.Errorf("network: read from %s: %w", c.address, err))
+			return
+		}
+		c.lastSeen.Store(time.Now().UnixNano())
+		c.mu.Lock()
+		ch, ok := c.pending[res.ID]
+		delete(c.pending, res.ID)
+		if len(c.pending) == 0 {
+			// an idle connection may stay silent
+			conn.SetReadDeadline(time.Time{})
+		}
+		c.mu.Unlock()
+		if ok {
+			ch <- res
+		}
+	}
+}
+
+// fail drops conn after err and releases every pending request. The client then
+// reconnects with WithReconnect and is closed with err otherwise.
+func (c *Client) fail(conn *peerConn, err error) {
+	c.mu.Lock()
+	if conn != c.conn || c.state != StateConnected {
+		c.mu.Unlock()
+		return
+	}
+	c.connErr = err
+	close(c.done)
+	c.releasePending()
+	if c.reconnect == nil {
+		c.err = err
+		c.setState(StateClosed)
+		close(c.closed)
+	} else {
+		c.setState(StateConnecting)
+		go c.redial()
+	}
+	state := c.state
+	c.mu.Unlock()
+	conn.Close()
+	c.notify(state)
+}
+
+// shutdown closes the client with err, and its connection when it is up.
+func (c *Client) shutdown(err error) error {
+	c.mu.Lock()
+	if c.state == StateClosed {
+		c.mu.Unlock()
+		return nil
+	}
+	var conn *peerConn
+	if c.state == StateConnected {
+		conn = c.conn
+		c.connErr = err
+		close(c.done)
+	}
+	c.err = err
+	c.setState(StateClosed)
+	close(c.closed)
+	c.releasePending()
+	c.mu.Unlock()
+	c.notify(StateClosed)
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// setState moves to state, waking requests queued while connecting. c.mu must be held.
+func (c *Client) setState(state State) {
+	if c.ready != nil {
+		close(c.ready)
+		c.ready = nil
+	}
+	if state == StateConnecting {
+		c.ready = make(chan struct{})
+	}
+	c.state = state
+}
+
+func (c *Client) notify(state State) {
+	if c.onState != nil {
+		c.onState(state)
+	}
+}
+
+// releasePending fails every request waiting for a response. c.mu must be held.
+func (c *Client) releasePending() {
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func (c *Client) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
@@ -0,0 +1,195 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// states collects the states of a client in the order they are reached.
+func states() (chan State, DialOption) {
+	ch := make(chan State, 16)
+	return ch, WithOnStateChange(func(s State) { ch <- s })
+}
+
+func waitState(t *testing.T, ch chan State, want State) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-ch:
+			if s == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("client never reached %s", want)
+		}
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	address := freeAddress(t)
+	l, err := Listen(address, echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, onState := states()
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	c, err := Dial(address, WithReconnect(policy, 4), onState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send(&Package{Option: 1, Data: "before"}); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Close()
+	waitState(t, ch, StateConnecting)
+	res := make(chan error, 1)
+	go func() {
+		pack, err := c.Send(&Package{Option: 1, Data: "queued"})
+		if err == nil && pack.Data != "queued" {
+			err = errors.New("wrong response " + pack.Data)
+		}
+		res <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if l, err = Listen(address, echo); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := <-res; err != nil {
+		t.Fatalf("queued request: %v", err)
+	}
+	if c.State() != StateConnected {
+		t.Fatalf("state %s, want connected", c.State())
+	}
+}
+
+func TestClientReconnectGivesUp(t *testing.T) {
+	address := freeAddress(t)
+	l, err := Listen(address, echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, onState := states()
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+	c, err := Dial(address, WithReconnect(policy, 4), onState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	waitState(t, ch, StateClosed)
+	if _, err := c.Send(&Package{Option: 1}); !errors.Is(err, ErrDial) {
+		t.Fatalf("got %v, want the dial error of the last attempt", err)
+	}
+}
+
+func TestClientSendAfterClose(t *testing.T) {
+	_, address := listen(t, echo)
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	start := time.Now()
+	if _, err := c.Send(&Package{Option: 1}); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("got %v, want ErrClientClosed", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("Send on a closed client waited")
+	}
+}
+
+func TestClientConcurrentRequests(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		remotes = make(map[string]bool)
+	)
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		mu.Lock()
+		remotes[pack.RemoteAddr] = true
+		mu.Unlock()
+		echo(conn, pack)
+	})
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		i := i
+		go func() {
+			want := fmt.Sprint("request ", i)
+			res, err := c.Send(&Package{Option: 1, Data: want})
+			if err == nil && res.Data != want {
+				err = fmt.Errorf("request %d got %q", i, res.Data)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(remotes) != 1 {
+		t.Fatalf("requests arrived on %d connections, want 1", len(remotes))
+	}
+}
+
+func TestClientReusesConnection(t *testing.T) {
+	var counters Counters
+	_, address := listen(t, echo, Observe(&counters))
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, data := range []string{"first", "second", "third"} {
+		res, err := c.Send(&Package{Option: 1, Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Data != data {
+			t.Fatalf("got %q, want %q", res.Data, data)
+		}
+	}
+	if n := counters.Snapshot().ConnsOpened; n != 1 {
+		t.Fatalf("%d connections for three requests", n)
+	}
+}
+
+// Packages written back to back are read one by one, none swallows the next.
+func TestConnBackToBack(t *testing.T) {
+	_, address := listen(t, echo)
+	raw, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	conn := NewConn(raw)
+	data := []string{"a", strings.Repeat("b", 64<<10), ""}
+	for i, d := range data {
+		if err := conn.WritePackage(&Package{ID: uint64(i + 1), Option: 1, Data: d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// handlers may answer out of order
+	for range data {
+		res, err := conn.ReadPackage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ID < 1 || res.ID > uint64(len(data)) || res.Data != data[res.ID-1] {
+			t.Fatalf("got ID %d with %d bytes", res.ID, len(res.Data))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Codec encodes packages into frame payloads. ID is written into every frame
+// header so a peer using another codec is detected instead of misparsed.
+type Codec interface {
+	ID() byte
+	Marshal(*Package) ([]byte, error)
+	Unmarshal([]byte) (*Package, error)
+}
+
+// Codec identifiers.
+const (
+	CodecJSON = 1 + iota
+	CodecGob
+	CodecProto
+)
+
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return CodecJSON }
+
+// Marshal is SerializePackage without the string round trip, which copies a block
+// payload twice.
+func (jsonCodec) Marshal(pack *Package) ([]byte, error) {
+	data, err := json.Marshal(pack)
+	if err != nil {
+		return nil, fmt.Errorf("network: can't serialize package: %w", err)
+	}
+	return data, nil
+}
+
+// Unmarshal is strict: unknown fields and data after the package are rejected.
+func (jsonCodec) Unmarshal(data []byte) (*Package, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var pack Package
+	if err := dec.Decode(&pack); err != nil {
+		// encoding/json has no error type for unknown fields
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return nil, fmt.Errorf("%w: %w: %w", ErrDeserialize, ErrUnknownField, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after package", ErrDeserialize)
+	}
+	return &pack, nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return CodecGob }
+
+func (gobCodec) Marshal(pack *Package) ([]byte, error) {
+	var buf bytes.Buffer
+	p := *pack
+	p.RemoteAddr, p.RemoteVersion = "", nil // local only, like their json:"-" tags
+	if err := gob.NewEncoder(&buf).Encode(&p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte) (*Package, error) {
+	var pack Package
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&pack); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDeserialize, err)
+	}
+	return &pack, nil
+}
+
+// decode unmarshals data with codec and rejects packages failing checkPackage.
+func decode(codec Codec, data []byte) (*Package, error) {
+	pack, err := codec.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPackage(pack); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// connConfig holds the settings a listener or client applies to each connection.
+type connConfig struct {
+	codec   Codec
+	maxSize int    // MaxPackageSize
+	bufSize int    // ReadBufferSize
+	key     []byte // AuthKey, frames aren't authenticated when empty
+	metrics Metrics
+}
+
+var defaultConnConfig = connConfig{codec: JSONCodec, maxSize: DMaxSize, bufSize: BuffSize}
+
+// encodedSize bounds the encoding of a package whose Data and Raw fit in maxSize:
+// base64 makes Raw a third larger, MinPackageSize leaves room for the other fields.
+// Decompressed frames and chunked transfers are held to it, the decoded package
+// to maxSize.
+func (cfg connConfig) encodedSize() int {
+	return cfg.maxSize/3*4 + MinPackageSize
+}
+
+func (cfg connConfig) validate() error {
+	if cfg.codec == nil {
+		return fmt.Errorf("%w: nil codec", ErrInvalidOption)
+	}
+	if cfg.maxSize < MinPackageSize || cfg.maxSize > MaxTransferSize {
+		return fmt.Errorf("%w: MaxPackageSize %d not in [%d, %d]",
+			ErrInvalidOption, cfg.maxSize, MinPackageSize, MaxTransferSize)
+	}
+	if cfg.bufSize <= 0 {
+		return fmt.Errorf("%w: ReadBufferSize %d is not positive", ErrInvalidOption, cfg.bufSize)
+	}
+	return nil
+}
+
+// peerConn is the Conn of a connection bound to a connConfig, handlers receive it
+// so responses are written with the codec of the listener.
+type peerConn struct {
+	net.Conn
+	cfg connConfig
+	wmu sync.Mutex    // serializes frame writes
+	r   *bufio.Reader // of cfg.bufSize, made on the first read
+}
+
+func withConfig(conn net.Conn, cfg connConfig) *peerConn {
+	return &peerConn{Conn: conn, cfg: cfg}
+}
+
+func (c *peerConn) WritePackage(pack *Package) error {
+	return writePackage(c, pack)
+}
+
+func (c *peerConn) ReadPackage() (*Package, error) {
+	return readPackage(c)
+}
+
+// reader buffers reads from the connection, so a frame header and a small payload
+// usually take a single read. Packages must only be read through it.
+func (c *peerConn) reader() *bufio.Reader {
+	if c.r == nil {
+		c.r = bufio.NewReaderSize(c.Conn, c.cfg.bufSize)
+	}
+	return c.r
+}
@@ -0,0 +1,160 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var codecs = []Codec{JSONCodec, GobCodec, ProtoCodec}
+
+// blockPayload is the JSON of a block of n transactions shaped like those of the
+// blockchain package, which imports network.
+func blockPayload(n int) string {
+	type transaction struct {
+		RandBytes []byte `json:"rand"`
+		PrevBlock []byte `json:"prev"`
+		Sender    string `json:"sender"`
+		Receiver  string `json:"receiver"`
+		Value     uint64 `json:"value"`
+		ToStorage uint64 `json:"fee"`
+		CurrHash  []byte `json:"hash"`
+		Signature []byte `json:"sig"`
+		PublicKey []byte `json:"key"`
+	}
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rand.Read(b)
+		return b
+	}
+	block := struct {
+		CurrHash     []byte            `json:"hash"`
+		PrevHash     []byte            `json:"prev"`
+		Nonce        uint64            `json:"nonce"`
+		Miner        string            `json:"miner"`
+		Timestamp    time.Time         `json:"time"`
+		Transactions []transaction     `json:"txs"`
+		Mapping      map[string]uint64 `json:"map"`
+	}{
+		CurrHash:  random(32),
+		PrevHash:  random(32),
+		Miner:     hex.EncodeToString(random(32)),
+		Timestamp: time.Now(),
+		Mapping:   make(map[string]uint64),
+	}
+	for i := 0; i < n; i++ {
+		tx := transaction{
+			RandBytes: random(32),
+			PrevBlock: block.PrevHash,
+			Sender:    hex.EncodeToString(random(32)),
+			Receiver:  hex.EncodeToString(random(32)),
+			Value:     uint64(i),
+			ToStorage: 1,
+			CurrHash:  random(32),
+			Signature: random(256),
+			PublicKey: random(270),
+		}
+		block.Transactions = append(block.Transactions, tx)
+		block.Mapping[tx.Sender] = uint64(i)
+	}
+	data, err := json.Marshal(block)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	pack := &Package{
+		ID:     7,
+		Option: OptionPushBlock,
+		Data:   blockPayload(3),
+		Raw:    []byte{0, 1, 2},
+		Chunk:  1,
+		Chunks: 2,
+		Relay:  "127.0.0.1:8080",
+		Error:  "reason",
+	}
+	for _, codec := range codecs {
+		data, err := codec.Marshal(pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decode(codec, data)
+		if err != nil {
+			t.Fatalf("codec %d: %v", codec.ID(), err)
+		}
+		if !reflect.DeepEqual(got, pack) {
+			t.Fatalf("codec %d: got %+v, want %+v", codec.ID(), got, pack)
+		}
+	}
+}
+
+func TestCodecOverListener(t *testing.T) {
+	for _, codec := range codecs[1:] {
+		_, address := listen(t, echo, UseCodec(codec))
+		c, err := Dial(address, WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		data := blockPayload(10)
+		res, err := c.Send(&Package{Option: 1, Data: data})
+		if err != nil {
+			t.Fatalf("codec %d: %v", codec.ID(), err)
+		}
+		if res.Data != data {
+			t.Fatalf("codec %d: block payload changed", codec.ID())
+		}
+	}
+}
+
+func TestCodecMismatch(t *testing.T) {
+	_, address := listen(t, echo, UseCodec(GobCodec))
+	_, err := Send(address, &Package{Option: 1})
+	if !errors.Is(err, ErrCodecMismatch) {
+		t.Fatalf("got %v, want ErrCodecMismatch", err)
+	}
+}
+
+func benchmarkCodecs(b *testing.B, run func(b *testing.B, codec Codec, pack *Package)) {
+	pack := &Package{Option: OptionPushBlock, Data: blockPayload(500)}
+	for _, codec := range codecs {
+		codec := codec
+		name := map[byte]string{CodecJSON: "json", CodecGob: "gob", CodecProto: "proto"}[codec.ID()]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(pack.Data)))
+			b.ReportAllocs()
+			run(b, codec, pack)
+		})
+	}
+}
+
+func BenchmarkCodecMarshal(b *testing.B) {
+	benchmarkCodecs(b, func(b *testing.B, codec Codec, pack *Package) {
+		for i := 0; i < b.N; i++ {
+			if _, err := codec.Marshal(pack); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCodecUnmarshal(b *testing.B) {
+	benchmarkCodecs(b, func(b *testing.B, codec Codec, pack *Package) {
+		data, err := codec.Marshal(pack)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := codec.Unmarshal(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnConcurrentWrites(t *testing.T) {
+	const writers = 50
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(client)
+	data := func(i int) string {
+		n := i * 1000
+		if i%25 == 0 {
+			n = ChunkSize + i // chunked
+		}
+		return strings.Repeat(fmt.Sprint(i%10), n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := conn.WritePackage(&Package{ID: uint64(i), Option: 1, Data: data(i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		client.Close()
+	}()
+
+	reader := NewConn(server)
+	seen := make(map[uint64]bool)
+	for len(seen) < writers {
+		pack, err := reader.ReadPackage()
+		if err != nil {
+			t.Fatalf("after %d packages: %v", len(seen), err)
+		}
+		if seen[pack.ID] || pack.Data != data(int(pack.ID)) {
+			t.Fatalf("package %d arrived twice or garbled", pack.ID)
+		}
+		seen[pack.ID] = true
+	}
+}
+
+// bufferConn is a net.Conn reading from and writing to a buffer, without deadlines.
+type bufferConn struct {
+	net.Conn
+	bytes.Buffer
+}
+
+func (c *bufferConn) Read(b []byte) (int, error)       { return c.Buffer.Read(b) }
+func (c *bufferConn) Write(b []byte) (int, error)      { return c.Buffer.Write(b) }
+func (c *bufferConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *bufferConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *bufferConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+
+func BenchmarkReadPackage(b *testing.B) {
+	cfg := connConfig{codec: JSONCodec, maxSize: 8 << 20, bufSize: BuffSize}
+	for _, size := range []int{4 << 10, 256 << 10, 2 << 20} {
+		var wire bufferConn
+		if err := withConfig(&wire, cfg).WritePackage(NewBytesPackage(1, make([]byte, size))); err != nil {
+			b.Fatal(err)
+		}
+		encoded := wire.Bytes()
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				conn := &bufferConn{Buffer: *bytes.NewBuffer(encoded)} // reads don't change encoded
+				if _, err := withConfig(conn, cfg).ReadPackage(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadFrame(b *testing.B) {
+	for _, size := range []int{4 << 10, 256 << 10, 2 << 20} {
+		buf := frame(CodecJSON, nil, make([]byte, size))
+		cfg := connConfig{codec: JSONCodec, maxSize: 8 << 20, bufSize: BuffSize}
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			r := bytes.NewReader(buf)
+			for i := 0; i < b.N; i++ {
+				r.Reset(buf)
+				if _, _, err := readFrame(r, cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens outbound connections, *net.Dialer and the dialers of
+// golang.org/x/net/proxy implement it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DefaultDialer opens the connections of Send, Dial and everything built on them.
+// Set it to a SOCKS5 dialer to route all outbound traffic through a proxy.
+var DefaultDialer Dialer = &net.Dialer{}
+
+// ErrProxy wraps failures reported by or reaching the proxy, as opposed to the peer
+// failing after the connection was established. It comes wrapped in ErrDial.
+var ErrProxy = errors.New("network: proxy failed")
+
+// SOCKS5 returns a dialer connecting through the SOCKS5 proxy at address,
+// e.g. Tor at 127.0.0.1:9050. auth may be nil.
+func SOCKS5(address string, auth *proxy.Auth) (Dialer, error) {
+	d, err := proxy.SOCKS5("tcp", address, auth, &net.Dialer{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProxy, err)
+	}
+	return socks5Dialer{d.(proxy.ContextDialer), address}, nil
+}
+
+type socks5Dialer struct {
+	proxy.ContextDialer
+	address string
+}
+
+func (d socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.ContextDialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrProxy, d.address, err)
+	}
+	return conn, nil
+}
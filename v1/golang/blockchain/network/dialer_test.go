@@ -0,0 +1,113 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// socks5Server is a minimal SOCKS5 proxy without authentication. It answers CONNECT
+// requests with reply, 0 connects to the target, and sends their targets to targets.
+func socks5Server(t *testing.T, reply byte, targets chan<- string) string {
+	t.Helper()
+	_, address := listenRaw(t, func(conn net.Conn) {
+		buf := make([]byte, 262)
+		// greeting: version, method count, methods
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+			return
+		}
+		conn.Write([]byte{5, 0})
+		// request: version, command, reserved, address type
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+			return
+		}
+		var host string
+		switch buf[3] {
+		case 1, 4:
+			n := net.IPv4len
+			if buf[3] == 4 {
+				n = net.IPv6len
+			}
+			if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+				return
+			}
+			host = net.IP(buf[:n]).String()
+		case 3:
+			if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+				return
+			}
+			n := int(buf[0])
+			if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+				return
+			}
+			host = string(buf[:n])
+		default:
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+		targets <- target
+		if reply != 0 {
+			conn.Write([]byte{5, reply, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		peer, err := net.Dial("tcp", target)
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer peer.Close()
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(peer, conn)
+		io.Copy(conn, peer)
+	})
+	return address
+}
+
+func TestSendSOCKS5(t *testing.T) {
+	_, address := listen(t, echo)
+	targets := make(chan string, 1)
+	d, err := SOCKS5(socks5Server(t, 0, targets), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useDialer(t, d)
+	res, err := Send(address, &Package{Option: 1, Data: "via proxy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "via proxy" {
+		t.Fatalf("got %q", res.Data)
+	}
+	if got := <-targets; got != address {
+		t.Fatalf("proxy connected to %s, want %s", got, address)
+	}
+}
+
+func TestSendSOCKS5Errors(t *testing.T) {
+	_, address := listen(t, echo)
+	targets := make(chan string, 1)
+	refusing, err := SOCKS5(socks5Server(t, 5, targets), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, err := SOCKS5(freeAddress(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, d := range map[string]Dialer{"refusing": refusing, "down": down} {
+		useDialer(t, d)
+		_, err := Send(address, &Package{Option: 1})
+		if !errors.Is(err, ErrProxy) || !errors.Is(err, ErrDial) {
+			t.Fatalf("%s proxy: got %v, want ErrProxy in ErrDial", name, err)
+		}
+	}
+}
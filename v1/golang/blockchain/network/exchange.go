@@ -0,0 +1,124 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	SharedPeers     = 32   // most peers sent in answer to OptionGetPeers
+	ExchangeFanout  = 8    // peers asked for addresses per exchange round
+	DefaultMaxPeers = 1024 // MaxPeers of a PeerManager made by NewPeerManager
+)
+
+// Exchange asks up to ExchangeFanout random peers for their peers every interval and
+// adds the valid new addresses, until ctx is done. self is the own address of the node.
+func (pm *PeerManager) Exchange(ctx context.Context, self string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pm.ExchangeOnce(self)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExchangeOnce runs one round of Exchange and returns the number of peers added.
+func (pm *PeerManager) ExchangeOnce(self string) int {
+	asked := pm.Random(ExchangeFanout)
+	added := 0
+	for address, r := range Broadcast(asked, &Package{Option: OptionGetPeers}) {
+		if r.Err != nil {
+			pm.record(address, r.Err, 0)
+			continue
+		}
+		list, err := parsePeers(address, r.Package)
+		if err != nil {
+			EventLog().Warn("network: peer exchange", "remote", address, "err", err)
+			pm.Bad(address)
+			continue
+		}
+		pm.Good(address, 0)
+		added += pm.merge(list, self)
+	}
+	return added
+}
+
+// merge adds the valid addresses of list other than self, up to MaxPeers,
+// and returns how many were new.
+func (pm *PeerManager) merge(list []string, self string) int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	added := 0
+	for _, address := range list {
+		if pm.MaxPeers > 0 && len(pm.peers) >= pm.MaxPeers {
+			break
+		}
+		if address == self || !validPeer(address) {
+			continue
+		}
+		if _, ok := pm.peers[address]; !ok {
+			pm.peers[address] = &PeerInfo{Address: address}
+			added++
+		}
+	}
+	return added
+}
+
+// sample returns up to n random addresses of peers without failures.
+func (pm *PeerManager) sample(n int) []string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	list := make([]string, 0, len(pm.peers))
+	for address, info := range pm.peers {
+		if info.Failures == 0 {
+			list = append(list, address)
+		}
+	}
+	rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	return list[:min(n, len(list))]
+}
+
+// validPeer reports whether address is a host:port another node can dial.
+func validPeer(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return !ip.IsUnspecified() && !ip.IsMulticast()
+	}
+	return len(host) <= 253
+}
+
+func fetchPeers(address string) ([]string, error) {
+	res, err := Send(address, &Package{Option: OptionGetPeers})
+	if err != nil {
+		return nil, err
+	}
+	return parsePeers(address, res)
+}
+
+// parsePeers decodes the response of address to OptionGetPeers.
+func parsePeers(address string, res *Package) ([]string, error) {
+	if res.Option != OptionGetPeers {
+		return nil, fmt.Errorf("network: get peers from %s: %s", address, res.Data)
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(res.Data), &list); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrDeserialize, address, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExchange(t *testing.T) {
+	_, addressA := peerNode(t)
+	b, addressB := peerNode(t)
+	c, addressC := peerNode(t)
+	b.Add(addressA)
+	c.Add(addressB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Exchange(ctx, addressC, 10*time.Millisecond)
+	}()
+	defer func() { cancel(); <-done }()
+	waitFor(t, func() bool {
+		for _, address := range c.List() {
+			if address == addressA {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestExchangeValidates(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(OptionGetPeers, func(context.Context, *Package) (string, error) {
+		list, _ := json.Marshal([]string{
+			"self.example:8080", "10.0.0.1:8080", "10.0.0.1:0", "0.0.0.0:8080",
+			"no port", ":8080", "224.0.0.1:8080", "[::1]:8080", "10.0.0.2:8080",
+		})
+		return string(list), nil
+	})
+	_, address := listen(t, mux.ServeConn)
+
+	pm := NewPeerManager()
+	pm.Add(address)
+	if added := pm.ExchangeOnce("self.example:8080"); added != 3 {
+		t.Fatalf("added %d peers: %v", added, pm.List())
+	}
+	for _, want := range []string{"10.0.0.1:8080", "[::1]:8080", "10.0.0.2:8080"} {
+		found := false
+		for _, got := range pm.List() {
+			found = found || got == want
+		}
+		if !found {
+			t.Fatalf("%s missing from %v", want, pm.List())
+		}
+	}
+}
+
+func TestExchangeMaxPeers(t *testing.T) {
+	remote, address := peerNode(t)
+	for i := 0; i < 2*SharedPeers; i++ {
+		remote.Add(fmt.Sprintf("10.0.0.%d:8080", i+1))
+	}
+	res, err := Send(address, &Package{Option: OptionGetPeers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := parsePeers(address, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) > SharedPeers {
+		t.Fatalf("shared %d peers, at most %d", len(shared), SharedPeers)
+	}
+
+	pm := NewPeerManager()
+	pm.MaxPeers = 5
+	pm.Add(address)
+	pm.ExchangeOnce("")
+	if n := pm.Len(); n != 5 {
+		t.Fatalf("%d peers, MaxPeers 5", n)
+	}
+}
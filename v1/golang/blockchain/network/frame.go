@@ -0,0 +1,172 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Frame layout: version (1 byte) | flags (1 byte) | codec (1 byte) |
+// payload length (8 bytes, big-endian) | CRC-32C of payload (4 bytes, big-endian) | payload
+// [| HMAC-SHA256 of header and payload (32 bytes) when FlagMAC is set].
+// Version 2 frames have no checksum and are still accepted.
+const (
+	ProtocolVersion = 3
+	HeaderSize      = 15
+
+	protocolVersionNoChecksum = 2
+	headerSizeNoChecksum      = 11
+)
+
+// Frame flags.
+const (
+	FlagGzip = 1 << iota // payload is gzip compressed
+	FlagMAC              // frame is followed by its HMAC under the shared AuthKey
+)
+
+// DefaultCompressionThreshold is a reasonable CompressionThreshold for block payloads.
+const DefaultCompressionThreshold = 8 << 10 // 8KiB
+
+// CompressionThreshold is the payload size in bytes above which outgoing
+// packages are gzip compressed, 0 disables compression.
+var CompressionThreshold = 0
+
+var (
+	ErrProtocolVersion = errors.New("network: unsupported protocol version")
+	ErrLegacyFrame     = errors.New("network: peer uses legacy EndBytes framing")
+	ErrFrameTooLarge   = errors.New("network: frame exceeds MaxPackageSize")
+	ErrFrameFlags      = errors.New("network: unknown frame flags")
+	ErrChecksum        = errors.New("network: frame checksum mismatch")
+	ErrUnauthenticated = errors.New("network: frame not authenticated")
+	ErrBadMAC          = errors.New("network: frame authentication failed")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// frame prepends the header to the payload so it can be written with a single Write.
+// The payload is compressed when it exceeds CompressionThreshold, and the frame
+// is authenticated when key isn't empty.
+func frame(codec byte, key, payload []byte) []byte {
+	var flags byte
+	if CompressionThreshold > 0 && len(payload) > CompressionThreshold {
+		if compressed, err := compress(payload); err == nil {
+			payload, flags = compressed, flags|FlagGzip
+		}
+	}
+	if len(key) > 0 {
+		flags |= FlagMAC
+	}
+	buf := make([]byte, HeaderSize+len(payload), HeaderSize+len(payload)+sha256.Size)
+	buf[0] = ProtocolVersion
+	buf[1] = flags
+	buf[2] = codec
+	binary.BigEndian.PutUint64(buf[3:11], uint64(len(payload)))
+	binary.BigEndian.PutUint32(buf[11:HeaderSize], crc32.Checksum(payload, crcTable))
+	copy(buf[HeaderSize:], payload)
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(buf)
+		buf = mac.Sum(buf)
+	}
+	return buf
+}
+
+// readFrame reads exactly one frame and returns its codec and decompressed payload.
+// The declared length is checked against cfg.maxSize and the decompressed one against
+// cfg.encodedSize, so a frame never costs much more than that, and the checksum and,
+// when cfg has a key, the MAC are verified before decompression.
+func readFrame(r io.Reader, cfg connConfig) (byte, []byte, error) {
+	var header [HeaderSize]byte
+	if _, err := io.ReadFull(r, header[:headerSizeNoChecksum]); err != nil {
+		return 0, nil, err
+	}
+	checksum := false
+	switch header[0] {
+	case ProtocolVersion:
+		if _, err := io.ReadFull(r, header[headerSizeNoChecksum:]); err != nil {
+			return 0, nil, err
+		}
+		checksum = true
+	case protocolVersionNoChecksum:
+	case '{':
+		// old peers send bare JSON terminated by EndBytes
+		return 0, nil, ErrLegacyFrame
+	default:
+		return 0, nil, fmt.Errorf("%w: %d", ErrProtocolVersion, header[0])
+	}
+	flags := header[1]
+	if flags&^(FlagGzip|FlagMAC) != 0 {
+		return 0, nil, fmt.Errorf("%w: %#x", ErrFrameFlags, flags)
+	}
+	size := binary.BigEndian.Uint64(header[3:11])
+	if size > uint64(cfg.maxSize) {
+		return 0, nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, size, cfg.maxSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if flags&FlagMAC != 0 {
+		var sum [sha256.Size]byte
+		if _, err := io.ReadFull(r, sum[:]); err != nil {
+			return 0, nil, err
+		}
+		if len(cfg.key) > 0 {
+			mac := hmac.New(sha256.New, cfg.key)
+			if checksum {
+				mac.Write(header[:])
+			} else {
+				mac.Write(header[:headerSizeNoChecksum])
+			}
+			mac.Write(payload)
+			if !hmac.Equal(mac.Sum(nil), sum[:]) {
+				return 0, nil, ErrBadMAC
+			}
+		}
+	} else if len(cfg.key) > 0 {
+		return 0, nil, ErrUnauthenticated
+	}
+	if checksum && crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[11:]) {
+		return 0, nil, ErrChecksum
+	}
+	if flags&FlagGzip != 0 {
+		payload, err := decompress(payload, cfg.encodedSize())
+		return header[2], payload, err
+	}
+	return header[2], payload, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress refuses to inflate beyond maxSize so a small frame can't expand into a zip bomb.
+func decompress(data []byte, maxSize int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	payload, err := io.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxSize {
+		return nil, fmt.Errorf("%w: decompressed payload", ErrFrameTooLarge)
+	}
+	return payload, nil
+}
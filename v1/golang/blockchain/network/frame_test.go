@@ -0,0 +1,258 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oldEndBytes terminated every package before length-prefixed frames.
+const oldEndBytes = "\000\005\007\001\001\007\005\000"
+
+func TestFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"empty", []byte{}},
+		{"sentinel", []byte(oldEndBytes)},
+		{"sentinel inside", []byte("{" + oldEndBytes + oldEndBytes + "}")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewReader(frame(CodecJSON, nil, tt.payload))
+			codec, payload, err := readFrame(buf, defaultConnConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if codec != CodecJSON || !bytes.Equal(payload, tt.payload) {
+				t.Fatalf("got codec %d payload %q, want %d %q", codec, payload, CodecJSON, tt.payload)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("%d bytes left after the frame", buf.Len())
+			}
+		})
+	}
+}
+
+func TestSendSentinelData(t *testing.T) {
+	_, address := listen(t, echo)
+	for _, pack := range []*Package{
+		{Option: 1, Data: oldEndBytes},
+		{Option: 1, Raw: []byte("a" + oldEndBytes + "b")},
+		{Option: 1},
+	} {
+		res, err := Send(address, pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Data != pack.Data || !bytes.Equal(res.Raw, pack.Raw) {
+			t.Fatalf("got %q %q, want %q %q", res.Data, res.Raw, pack.Data, pack.Raw)
+		}
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	header := make([]byte, HeaderSize)
+	header[0] = ProtocolVersion
+	header[2] = CodecJSON
+	binary.BigEndian.PutUint64(header[3:11], DMaxSize+1)
+	// no payload follows, the length alone must be rejected
+	_, _, err := readFrame(bytes.NewReader(header), defaultConnConfig)
+	if !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("got %v, want ErrFrameTooLarge", err)
+	}
+}
+
+func TestReadFrameOldPeers(t *testing.T) {
+	legacy := []byte(`{"Option":1,"Data":""}` + oldEndBytes)
+	if _, _, err := readFrame(bytes.NewReader(legacy), defaultConnConfig); !errors.Is(err, ErrLegacyFrame) {
+		t.Fatalf("legacy frame: got %v, want ErrLegacyFrame", err)
+	}
+	unknown := make([]byte, HeaderSize)
+	unknown[0] = ProtocolVersion + 1
+	if _, _, err := readFrame(bytes.NewReader(unknown), defaultConnConfig); !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("unknown version: got %v, want ErrProtocolVersion", err)
+	}
+}
+
+func TestReadFrameNoChecksum(t *testing.T) {
+	payload := []byte("payload")
+	buf := make([]byte, headerSizeNoChecksum, headerSizeNoChecksum+len(payload))
+	buf[0] = protocolVersionNoChecksum
+	buf[2] = CodecJSON
+	binary.BigEndian.PutUint64(buf[3:11], uint64(len(payload)))
+	buf = append(buf, payload...)
+	_, got, err := readFrame(bytes.NewReader(buf), defaultConnConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+// compressAbove sets CompressionThreshold until the test ends.
+func compressAbove(t *testing.T, n int) {
+	saved := CompressionThreshold
+	CompressionThreshold = n
+	t.Cleanup(func() { CompressionThreshold = saved })
+}
+
+func TestFrameCompression(t *testing.T) {
+	compressAbove(t, DefaultCompressionThreshold)
+	payload := bytes.Repeat([]byte(`{"Sender":"a","Receiver":"b","Value":1}`), 1<<20/40)
+	buf := frame(CodecJSON, nil, payload)
+	if buf[1]&FlagGzip == 0 || len(buf) >= len(payload) {
+		t.Fatalf("frame of %d bytes for a %d byte payload isn't compressed", len(buf), len(payload))
+	}
+	_, got, err := readFrame(bytes.NewReader(buf), defaultConnConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatal("decompressed payload differs")
+	}
+
+	small := frame(CodecJSON, nil, []byte("{}"))
+	if small[1]&FlagGzip != 0 {
+		t.Fatal("payload under the threshold was compressed")
+	}
+}
+
+func TestSendCompressed(t *testing.T) {
+	compressAbove(t, DefaultCompressionThreshold)
+	_, address := listen(t, echo)
+	data := strings.Repeat("compressible ", 1<<20/13)
+	res, err := Send(address, &Package{Option: 1, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != data {
+		t.Fatalf("got %d bytes back, want %d", len(res.Data), len(data))
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	compressAbove(t, 1)
+	cfg := defaultConnConfig
+	cfg.maxSize = MinPackageSize
+	buf := frame(CodecJSON, nil, make([]byte, cfg.encodedSize()+1))
+	if _, _, err := readFrame(bytes.NewReader(buf), cfg); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("got %v, want ErrFrameTooLarge", err)
+	}
+}
+
+func TestSendCompressible(t *testing.T) {
+	compressAbove(t, DefaultCompressionThreshold)
+	_, address := listen(t, echo)
+	raw := bytes.Repeat([]byte{0}, 3<<19) // 1.5MiB, 2MiB once base64 encoded
+	res, err := Send(address, NewBytesPackage(1, raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.Raw, raw) {
+		t.Fatalf("got %d bytes back, want %d", len(res.Raw), len(raw))
+	}
+}
+
+// MaxPackageSize holds for the decoded package however small its frame is.
+func TestDecodedSizeLimit(t *testing.T) {
+	compressAbove(t, 1)
+	cfg := defaultConnConfig
+	cfg.maxSize = 4 * MinPackageSize
+	for _, size := range []int{cfg.maxSize, cfg.maxSize + 1} {
+		client, server := net.Pipe()
+		go func() {
+			defer client.Close()
+			withConfig(client, cfg).WritePackage(NewBytesPackage(1, make([]byte, size)))
+		}()
+		pack, err := withConfig(server, cfg).ReadPackage()
+		server.Close()
+		switch {
+		case size <= cfg.maxSize && (err != nil || len(pack.Raw) != size):
+			t.Fatalf("%d bytes of Raw: got %v", size, err)
+		case size > cfg.maxSize && !errors.Is(err, ErrTransferTooLarge):
+			t.Fatalf("%d bytes of Raw: got %v, want ErrTransferTooLarge", size, err)
+		}
+	}
+}
+
+func TestListenerRejectsZipBomb(t *testing.T) {
+	_, address := listen(t, echo, MaxPackageSize(4*MinPackageSize))
+	compressAbove(t, 1)
+	bomb := frame(CodecJSON, nil, bytes.Repeat([]byte(" "), 1<<20))
+	if len(bomb) > 4*MinPackageSize {
+		t.Fatalf("bomb frame is %d bytes", len(bomb))
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(bomb); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("listener kept the connection: %v", err)
+	}
+}
+
+// flipProxy forwards everything written to the returned conn to the other one,
+// flipping the byte at offset.
+func flipProxy(offset int) (client, server net.Conn) {
+	client, in := net.Pipe()
+	out, server := net.Pipe()
+	go func() {
+		defer out.Close()
+		buf := make([]byte, 4096)
+		seen := 0
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				return
+			}
+			if i := offset - seen; i >= 0 && i < n {
+				buf[i] ^= 0x20
+			}
+			seen += n
+			if _, err := out.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	return client, server
+}
+
+func TestChecksumDetectsFlippedByte(t *testing.T) {
+	client, server := flipProxy(HeaderSize + 5)
+	defer client.Close()
+	defer server.Close()
+	go NewConn(client).WritePackage(&Package{Option: 1, Data: "amount 100"})
+	_, err := NewConn(server).ReadPackage()
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got %v, want ErrChecksum", err)
+	}
+	if errors.Is(err, ErrDeserialize) || errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("corruption reported as %v", err)
+	}
+}
+
+func TestReadFrameChecksum(t *testing.T) {
+	payload := []byte(`{"Option":1,"Data":"amount 100"}`)
+	good := frame(CodecJSON, nil, payload)
+	// every payload byte and every byte of the checksum itself
+	for i := 11; i < len(good); i++ {
+		buf := bytes.Clone(good)
+		buf[i] ^= 1
+		if _, _, err := readFrame(bytes.NewReader(buf), defaultConnConfig); !errors.Is(err, ErrChecksum) {
+			t.Fatalf("byte %d flipped: got %v, want ErrChecksum", i, err)
+		}
+	}
+}
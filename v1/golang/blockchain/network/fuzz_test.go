@@ -0,0 +1,96 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeserializeStrict(t *testing.T) {
+	tests := []struct {
+		data string
+		want error
+	}{
+		{`{"Option":1,"Smuggled":"x"}`, ErrUnknownField},
+		{fmt.Sprintf(`{"Option":%d}`, int64(math.MaxInt32)+1), ErrOptionRange},
+		{fmt.Sprintf(`{"Option":%d}`, lowestOption-1), ErrOptionRange},
+		{`{"Option":1}{"Option":2}`, ErrDeserialize},
+		{`{"Option":1,"Chunk":-1}`, ErrDeserialize},
+		{strings.Repeat("[", 10000), ErrDeserialize},
+	}
+	for _, tt := range tests {
+		_, err := decode(JSONCodec, []byte(tt.data))
+		if !errors.Is(err, tt.want) || !errors.Is(err, ErrDeserialize) {
+			t.Errorf("%.40s: got %v, want %v", tt.data, err, tt.want)
+		}
+	}
+	if DeserializePackage(`{"Option":1,"Smuggled":"x"}`) != nil {
+		t.Fatal("DeserializePackage accepted an unknown field")
+	}
+}
+
+// frameSeeds are frames a hostile or broken peer might send.
+func frameSeeds() [][]byte {
+	valid := frame(CodecJSON, nil, []byte(`{"Option":1,"Data":"seed"}`))
+	badCRC := append([]byte(nil), valid...)
+	badCRC[11] ^= 0xff
+	oversized := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint64(oversized[3:11], math.MaxUint64)
+	return [][]byte{
+		valid,
+		valid[:HeaderSize-3], // truncated header
+		valid[:len(valid)-2], // truncated payload
+		badCRC,
+		oversized,
+		[]byte(`{"Option":1}` + oldEndBytes),
+		{},
+	}
+}
+
+func FuzzReadPackage(f *testing.F) {
+	for _, seed := range frameSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		client, server := net.Pipe()
+		defer server.Close()
+		go func() {
+			client.Write(data)
+			client.Close()
+		}()
+		pack, err := NewConn(server).ReadPackage()
+		if err == nil {
+			if err := checkPackage(pack); err != nil {
+				t.Fatalf("read a package failing its checks: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzDeserializePackage(f *testing.F) {
+	for _, seed := range []string{
+		`{"Option":1,"Data":"x"}`,
+		`{"ID":3,"Option":-1,"Error":"failed","Raw":"AAE="}`,
+		`{"Option":1,"Data":"x"`,
+		`{"Option":1e100}`,
+		`{"Option":1,"Unknown":true}`,
+		"{\n\t\"Option\": 1\n}",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		pack := DeserializePackage(data)
+		if pack == nil {
+			return
+		}
+		again := DeserializePackage(SerializePackage(pack))
+		if !reflect.DeepEqual(pack, again) {
+			t.Fatalf("round trip changed %+v into %+v", pack, again)
+		}
+	})
+}
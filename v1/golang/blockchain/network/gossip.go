@@ -0,0 +1,145 @@
+package network
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultGossipCacheSize = 4096
+	DefaultGossipTTL       = 10 * time.Minute
+)
+
+// Gossiper relays packages between the peers of a PeerManager. A message is
+// identified by the hash of its Option and payload, each node handles and forwards
+// it once and never back to the node it came from.
+type Gossiper struct {
+	self  string
+	peers *PeerManager
+	seen  *seenCache
+}
+
+// GossipOption configures a Gossiper.
+type GossipOption func(*Gossiper)
+
+// GossipCache sets how many message IDs are remembered, DefaultGossipCacheSize by default.
+func GossipCache(size int) GossipOption {
+	return func(g *Gossiper) { g.seen.size = size }
+}
+
+// GossipTTL sets how long a message ID is remembered, DefaultGossipTTL by default.
+func GossipTTL(d time.Duration) GossipOption {
+	return func(g *Gossiper) { g.seen.ttl = d }
+}
+
+// NewGossiper relays to the peers of pm, self is the listen address of the node.
+func NewGossiper(self string, pm *PeerManager, opts ...GossipOption) *Gossiper {
+	g := &Gossiper{
+		self:  self,
+		peers: pm,
+		seen: &seenCache{
+			size:  DefaultGossipCacheSize,
+			ttl:   DefaultGossipTTL,
+			order: list.New(),
+			items: make(map[[sha256.Size]byte]*list.Element),
+		},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Gossip sends pack to every peer, a message seen already is dropped and nil returned.
+func (g *Gossiper) Gossip(pack *Package) map[string]Result {
+	if !g.seen.add(messageID(pack)) {
+		return nil
+	}
+	return g.forward(pack, "")
+}
+
+// HandleFunc registers option on mux as a gossiped message: handle is called once
+// per message and the message is then forwarded to every peer but its relay.
+func (g *Gossiper) HandleFunc(mux *Mux, option Option, handle func(*Package) error) {
+	mux.HandleFunc(option, func(_ context.Context, pack *Package) (string, error) {
+		if !g.seen.add(messageID(pack)) {
+			return "", nil
+		}
+		if err := handle(pack); err != nil {
+			return "", err
+		}
+		go g.forward(pack, pack.Relay)
+		return "", nil
+	})
+}
+
+func (g *Gossiper) forward(pack *Package, from string) map[string]Result {
+	var to []string
+	for _, address := range g.peers.List() {
+		if address != from && address != g.self {
+			to = append(to, address)
+		}
+	}
+	relayed := &Package{Option: pack.Option, Data: pack.Data, Raw: pack.Raw, Relay: g.self}
+	res := Broadcast(to, relayed)
+	for address, r := range res {
+		g.peers.record(address, r.Err, 0)
+	}
+	return res
+}
+
+// messageID is the SHA-256 over the Option and payload of pack.
+func messageID(pack *Package) [sha256.Size]byte {
+	h := sha256.New()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(pack.Option))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(len(pack.Data)))
+	h.Write(buf[:])
+	h.Write([]byte(pack.Data))
+	h.Write(pack.Raw)
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
+
+// seenCache is an LRU of message IDs whose entries also expire after ttl.
+type seenCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List // of seenEntry, most recent first
+	items map[[sha256.Size]byte]*list.Element
+}
+
+type seenEntry struct {
+	id   [sha256.Size]byte
+	seen time.Time
+}
+
+// add records id and reports whether it was new.
+func (c *seenCache) add(id [sha256.Size]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if e, ok := c.items[id]; ok {
+		if c.ttl <= 0 || now.Sub(e.Value.(seenEntry).seen) < c.ttl {
+			c.order.MoveToFront(e)
+			return false
+		}
+		c.order.Remove(e)
+		delete(c.items, id)
+	}
+	c.items[id] = c.order.PushFront(seenEntry{id, now})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(seenEntry).id)
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"crypto/sha256"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGossipOncePerNode(t *testing.T) {
+	const nodes = 4
+	gossipers := make([]*Gossiper, nodes)
+	addresses := make([]string, nodes)
+	handled := make([]atomic.Int32, nodes)
+	muxes := make([]*Mux, nodes)
+	for i := range addresses {
+		muxes[i] = NewMux()
+		_, addresses[i] = listen(t, muxes[i].ServeConn)
+	}
+	for i := range gossipers {
+		i := i
+		pm := NewPeerManager()
+		for _, address := range addresses {
+			pm.Add(address)
+		}
+		gossipers[i] = NewGossiper(addresses[i], pm)
+		gossipers[i].HandleFunc(muxes[i], 1, func(*Package) error {
+			handled[i].Add(1)
+			return nil
+		})
+	}
+
+	if res := gossipers[0].Gossip(&Package{Option: 1, Data: "block 7"}); len(res) != nodes-1 {
+		t.Fatalf("sent to %d peers, want %d", len(res), nodes-1)
+	}
+	waitFor(t, func() bool {
+		return handled[1].Load() > 0 && handled[2].Load() > 0 && handled[3].Load() > 0
+	})
+	time.Sleep(100 * time.Millisecond) // let the relays settle
+	for i := range handled {
+		want := int32(1)
+		if i == 0 {
+			want = 0 // the origin doesn't handle its own message
+		}
+		if n := handled[i].Load(); n != want {
+			t.Errorf("node %d handled the message %d times, want %d", i, n, want)
+		}
+	}
+	if res := gossipers[0].Gossip(&Package{Option: 1, Data: "block 7"}); res != nil {
+		t.Fatalf("a seen message was sent again to %d peers", len(res))
+	}
+}
+
+func TestSeenCache(t *testing.T) {
+	id := func(b byte) [sha256.Size]byte { return [sha256.Size]byte{b} }
+	c := NewGossiper("", NewPeerManager(), GossipCache(2), GossipTTL(time.Hour)).seen
+	if !c.add(id(1)) || c.add(id(1)) {
+		t.Fatal("a repeated ID was new")
+	}
+	c.add(id(2))
+	c.add(id(3)) // evicts 1, the least recently seen
+	if !c.add(id(1)) {
+		t.Fatal("the evicted ID was still known")
+	}
+
+	c = NewGossiper("", NewPeerManager(), GossipTTL(time.Millisecond)).seen
+	c.add(id(1))
+	time.Sleep(2 * time.Millisecond)
+	if !c.add(id(1)) {
+		t.Fatal("an expired ID was still known")
+	}
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Protocol version exchanged in the handshake. Peers with a different major
+// version are refused, minor versions only add features.
+const (
+	ProtocolMajor = 1
+	ProtocolMinor = 0
+)
+
+// OptionHandshake is the Option of the version packages exchanged when a
+// connection opens on a listener with Handshake.
+const OptionHandshake Option = -2
+
+// HandshakeTimeout bounds how long a peer may take to send its version.
+const HandshakeTimeout = WaitTime * time.Second
+
+var (
+	ErrHandshake       = errors.New("network: handshake failed")
+	ErrVersionMismatch = errors.New("network: incompatible protocol version")
+)
+
+// Version describes a node. Major and Minor default to ProtocolMajor and ProtocolMinor.
+type Version struct {
+	Major    int
+	Minor    int
+	Software string `json:",omitempty"` // node software version
+	Height   uint64 `json:",omitempty"` // chain height of blockchain nodes
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d (%s, height %d)", v.Major, v.Minor, v.Software, v.Height)
+}
+
+func (v Version) withDefaults() Version {
+	if v.Major == 0 {
+		v.Major, v.Minor = ProtocolMajor, ProtocolMinor
+	}
+	return v
+}
+
+// Handshake makes the listener require a version package as the first package of every
+// connection and answer it with local. Handlers find the peer version in Package.RemoteVersion.
+func Handshake(local Version) ListenOption {
+	return func(l *Listener) {
+		local = local.withDefaults()
+		l.version = &local
+	}
+}
+
+// WithHandshake makes Dial exchange versions with a listener using Handshake.
+func WithHandshake(local Version) DialOption {
+	return func(c *Client) {
+		local = local.withDefaults()
+		c.version = &local
+	}
+}
+
+func versionPackage(v *Version) *Package {
+	data, _ := json.Marshal(v)
+	return &Package{Option: OptionHandshake, Data: string(data)}
+}
+
+func parseVersion(pack *Package) (*Version, error) {
+	if pack.Option != OptionHandshake {
+		return nil, fmt.Errorf("%w: expected version package, got %v", ErrHandshake, pack.Option)
+	}
+	var v Version
+	if err := json.Unmarshal([]byte(pack.Data), &v); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	return &v, nil
+}
+
+func checkVersion(local, remote *Version) error {
+	if local.Major != remote.Major {
+		return fmt.Errorf("%w: local %d.%d, remote %d.%d",
+			ErrVersionMismatch, local.Major, local.Minor, remote.Major, remote.Minor)
+	}
+	return nil
+}
+
+// acceptHandshake reads the peer version and answers with local, or with an
+// OptionError package describing why the connection is refused.
+func acceptHandshake(conn Conn, local *Version, writeTimeout time.Duration) (*Version, error) {
+	conn.SetReadDeadline(time.Now().Add(HandshakeTimeout))
+	pack, err := conn.ReadPackage()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	remote, err := parseVersion(pack)
+	if err == nil {
+		err = checkVersion(local, remote)
+	}
+	conn.SetWriteDeadline(deadline(writeTimeout))
+	if err != nil {
+		conn.WritePackage(errorPackage(pack, err))
+		return nil, err
+	}
+	if err := conn.WritePackage(versionPackage(local)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	return remote, nil
+}
+
+// dialHandshake sends local and waits for the listener's version.
+func dialHandshake(conn Conn, local *Version) (*Version, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := conn.WritePackage(versionPackage(local)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	pack, err := conn.ReadPackage()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	if err := pack.Err(); err != nil {
+		return nil, fmt.Errorf("%w: refused: %w", ErrHandshake, err)
+	}
+	remote, err := parseVersion(pack)
+	if err != nil {
+		return nil, err
+	}
+	return remote, checkVersion(local, remote)
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// software answers with the software version the peer sent in the handshake.
+func software(conn Conn, pack *Package) {
+	conn.WritePackage(&Package{ID: pack.ID, Option: pack.Option, Data: pack.RemoteVersion.Software})
+}
+
+func TestHandshake(t *testing.T) {
+	_, address := listen(t, software, Handshake(Version{Software: "server", Height: 7}))
+	c, err := Dial(address, WithHandshake(Version{Major: ProtocolMajor, Minor: ProtocolMinor + 1, Software: "client"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	remote := c.RemoteVersion()
+	if remote == nil || remote.Software != "server" || remote.Height != 7 || remote.Major != ProtocolMajor {
+		t.Fatalf("remote version %v", remote)
+	}
+	res, err := c.Send(&Package{Option: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "client" {
+		t.Fatalf("handler saw software %q, want client", res.Data)
+	}
+}
+
+func TestHandshakeMajorMismatch(t *testing.T) {
+	_, address := listen(t, software, Handshake(Version{}))
+	_, err := Dial(address, WithHandshake(Version{Major: ProtocolMajor + 1}))
+	if !errors.Is(err, ErrHandshake) || !strings.Contains(err.Error(), ErrVersionMismatch.Error()) {
+		t.Fatalf("got %v, want a refused handshake", err)
+	}
+}
+
+func TestHandshakeOldClient(t *testing.T) {
+	_, address := listen(t, software, Handshake(Version{}))
+	// a client predating the handshake sends its request right away
+	_, err := Send(address, &Package{Option: 1})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || !strings.Contains(remote.Message, "expected version package") {
+		t.Fatalf("got %v, want a remote handshake error", err)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits HandshakeTimeout")
+	}
+	dropped := make(chan error, 1)
+	_, address := listen(t, software, Handshake(Version{}), OnConnError(func(_ net.Conn, err error) {
+		dropped <- err
+	}))
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	select {
+	case err := <-dropped:
+		if !errors.Is(err, ErrHandshake) {
+			t.Fatalf("got %v, want ErrHandshake", err)
+		}
+	case <-time.After(HandshakeTimeout + 5*time.Second):
+		t.Fatal("silent peer not dropped")
+	}
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// echo answers every package with a copy of it.
+func echo(conn Conn, pack *Package) {
+	conn.WritePackage(&Package{ID: pack.ID, Option: pack.Option, Data: pack.Data, Raw: pack.Raw})
+}
+
+// listen serves handle on a free local port until the test ends.
+func listen(t testing.TB, handle func(Conn, *Package), opts ...ListenOption) (*Listener, string) {
+	t.Helper()
+	l, err := Listen("127.0.0.1:0", handle, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, l.Addr().String()
+}
+
+// freeAddress is a local address nothing listens on.
+func freeAddress(t testing.TB) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// listenRaw runs serve on every connection accepted on a free local port, then
+// closes the connection.
+func listenRaw(t testing.TB, serve func(net.Conn)) (net.Listener, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	return l, l.Addr().String()
+}
+
+type dialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f dialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// useDialer replaces DefaultDialer until the test ends.
+func useDialer(t testing.TB, d Dialer) {
+	saved := DefaultDialer
+	DefaultDialer = d
+	t.Cleanup(func() { DefaultDialer = saved })
+}
+
+// contextTimeout is cancelled after d or when the test ends.
+func contextTimeout(t testing.TB, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// waitFor polls cond until it holds, failing the test after 5 seconds.
+func waitFor(t testing.TB, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
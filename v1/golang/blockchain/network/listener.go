@@ -0,0 +1,503 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReadTimeout  = 30 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// Listener accepts connections and serves them with the handle passed to Listen.
+type Listener struct {
+	net.Listener
+	wg     sync.WaitGroup
+	ctx    context.Context // of the handlers, cancelled when Shutdown force-closes
+	cancel context.CancelFunc
+	stop   func() bool // unregisters the Close on the ListenContext ctx
+
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	handlerTimeout time.Duration
+	maxConns       int
+	maxConnsPerIP  int
+	peer           connConfig
+	version        *Version
+	encrypt        bool
+	limiter        *rateLimiter
+	allow          []string
+	allowed        []*net.IPNet
+	onConnError    func(net.Conn, error)
+	noRecover      bool
+
+	mu      sync.Mutex
+	conns   map[net.Conn]bool // true while a handler runs on the conn
+	perIP   map[string]int
+	bans    map[string]time.Time // banned IP to ban expiry
+	closing bool
+}
+
+// ListenOption configures a Listener.
+type ListenOption func(*Listener)
+
+// ReadTimeout bounds how long the server waits for each incoming package,
+// including the idle time before it on a persistent connection. 0 disables it.
+func ReadTimeout(d time.Duration) ListenOption {
+	return func(l *Listener) { l.readTimeout = d }
+}
+
+// WriteTimeout bounds how long the server spends writing each response. 0 disables it.
+func WriteTimeout(d time.Duration) ListenOption {
+	return func(l *Listener) { l.writeTimeout = d }
+}
+
+// MaxConns caps the number of connections served at once, excess ones are closed
+// right after Accept. 0 means no limit.
+func MaxConns(n int) ListenOption {
+	return func(l *Listener) { l.maxConns = n }
+}
+
+// MaxConnsPerIP caps the number of connections served at once from one remote IP.
+// 0 means no limit.
+func MaxConnsPerIP(n int) ListenOption {
+	return func(l *Listener) { l.maxConnsPerIP = n }
+}
+
+// UseCodec sets the codec packages are exchanged in, JSONCodec by default.
+// Clients must use the same codec.
+func UseCodec(codec Codec) ListenOption {
+	return func(l *Listener) { l.peer.codec = codec }
+}
+
+// MaxPackageSize caps the size of an incoming package, DMaxSize by default, up to
+// MaxTransferSize. It applies to the frames read and to the Data and Raw of the
+// decoded package, not to their encoding, so gzip and base64 don't count against
+// it. Packages over half of it, or over ChunkSize, are chunked.
+func MaxPackageSize(n int) ListenOption {
+	return func(l *Listener) { l.peer.maxSize = n }
+}
+
+// ReadBufferSize is the size of the read buffer of each connection, BuffSize by default.
+func ReadBufferSize(n int) ListenOption {
+	return func(l *Listener) { l.peer.bufSize = n }
+}
+
+// AuthKey makes the listener accept only frames authenticated with key and
+// authenticate its responses, clients must use WithAuthKey with the same key.
+func AuthKey(key []byte) ListenOption {
+	return func(l *Listener) { l.peer.key = key }
+}
+
+// OnConnError is called with the reason a connection is dropped, e.g. a package over
+// MaxPackageSize or a read timeout. A peer closing the connection isn't reported.
+func OnConnError(f func(conn net.Conn, err error)) ListenOption {
+	return func(l *Listener) { l.onConnError = f }
+}
+
+// RecoverPanics sets whether a panicking handler is recovered, true by default: the
+// panic is logged to EventLog with its stack, the request is answered with
+// ErrHandlerPanic and the connection is closed. Pass false to let panics crash the
+// process while debugging.
+func RecoverPanics(on bool) ListenOption {
+	return func(l *Listener) { l.noRecover = !on }
+}
+
+// HandlerTimeout bounds each call of the handle passed to Listen, 0, the default,
+// disables it. The context of the package is done once d passes, see Package.Context.
+// A handler not returning by then has its request answered with ErrHandlerTimeout
+// and its connection closed, later writes of the handler are dropped.
+func HandlerTimeout(d time.Duration) ListenOption {
+	return func(l *Listener) { l.handlerTimeout = d }
+}
+
+var (
+	// ErrHandlerPanic answers a request whose handler panicked, see RecoverPanics.
+	ErrHandlerPanic = errors.New("network: handler panicked")
+	// ErrHandlerTimeout answers a request whose handler overran HandlerTimeout.
+	ErrHandlerTimeout = errors.New("network: handler timed out")
+)
+
+// Listen address ip:port. A connection may carry any number of packages, handle is
+// called for each in turn until the peer closes the connection or stays silent
+// longer than ReadTimeout.
+func Listen(address string, handle func(Conn, *Package), opts ...ListenOption) (*Listener, error) {
+	return ListenContext(context.Background(), address, handle, opts...)
+}
+
+// ListenContext address ip:port, the listener is closed once ctx is done.
+func ListenContext(ctx context.Context, address string, handle func(Conn, *Package), opts ...ListenOption) (*Listener, error) {
+	listener, err := listenTCP(address)
+	if err != nil {
+		return nil, err
+	}
+	return newListener(ctx, listener, handle, opts)
+}
+
+func listenTCP(address string) (net.Listener, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("network: listen %q: invalid port %q", address, portStr)
+	}
+	// an empty host binds every IPv4 and IPv6 interface
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, portStr))
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", address, err)
+	}
+	return listener, nil
+}
+
+func newListener(ctx context.Context, listener net.Listener, handle func(Conn, *Package), opts []ListenOption) (*Listener, error) {
+	l := &Listener{
+		Listener:     listener,
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		peer:         defaultConnConfig,
+		conns:        make(map[net.Conn]bool),
+		perIP:        make(map[string]int),
+		bans:         make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := l.peer.validate(); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if l.limiter != nil && (l.limiter.rate <= 0 || l.limiter.burst < 1) {
+		listener.Close()
+		return nil, fmt.Errorf("%w: RateLimit %v/s burst %v", ErrInvalidOption, l.limiter.rate, l.limiter.burst)
+	}
+	var err error
+	if l.allowed, err = parseCIDRs(l.allow); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	l.wg.Add(1)
+	go l.serve(handle)
+	l.stop = context.AfterFunc(ctx, func() { l.Close() })
+	return l, nil
+}
+
+// Port returns the bound port, useful after listening on port 0.
+func (l *Listener) Port() int {
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// Close stops accepting connections and waits for in-flight handlers to finish.
+func (l *Listener) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting connections, closes idle ones and waits for in-flight
+// handlers to finish. Once ctx is done the contexts of the handlers are cancelled,
+// the remaining connections are force-closed and ctx.Err() is returned.
+func (l *Listener) Shutdown(ctx context.Context) error {
+	defer l.cancel()
+	// a long-lived ListenContext ctx would otherwise keep the listener reachable
+	l.stop()
+	l.mu.Lock()
+	l.closing = true
+	l.mu.Unlock()
+	err := l.Listener.Close()
+	l.closeConns(false)
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if errors.Is(err, net.ErrClosed) {
+			// already closed by an earlier Shutdown or the accept loop
+			err = nil
+		}
+		return err
+	case <-ctx.Done():
+		l.cancel()
+		l.closeConns(true)
+		return ctx.Err()
+	}
+}
+
+// closeConns closes idle connections, and active ones too when force is set.
+func (l *Listener) closeConns(force bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for conn, active := range l.conns {
+		if force || !active {
+			conn.Close()
+		}
+	}
+}
+
+// Conns returns the number of connections being served.
+func (l *Listener) Conns() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// ConnsFrom returns the number of connections being served from ip.
+func (l *Listener) ConnsFrom(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.perIP[ip]
+}
+
+// admit starts tracking conn unless the listener is closing, the IP is refused
+// or a connection limit is reached.
+func (l *Listener) admit(conn net.Conn) bool {
+	ip := remoteIP(conn)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing || l.refused(ip) {
+		return false
+	}
+	if l.maxConns > 0 && len(l.conns) >= l.maxConns {
+		return false
+	}
+	if l.maxConnsPerIP > 0 && l.perIP[ip] >= l.maxConnsPerIP {
+		return false
+	}
+	l.conns[conn] = false
+	l.perIP[ip]++
+	return true
+}
+
+// setState records whether a handler runs on conn, false means conn must be dropped.
+func (l *Listener) setState(conn net.Conn, active bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing {
+		return false
+	}
+	l.conns[conn] = active
+	return true
+}
+
+func (l *Listener) forget(conn net.Conn) {
+	ip := remoteIP(conn)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, conn)
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// remoteIP is the canonical IP of the peer of conn, see canonicalIP.
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return canonicalIP(host)
+}
+
+func (l *Listener) serve(handle func(Conn, *Package)) {
+	defer l.wg.Done()
+	defer l.Listener.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				EventLog().Error("network: accept", "addr", l.Addr().String(), "err", err)
+			}
+			break
+		}
+		if !l.admit(conn) {
+			EventLog().Debug("network: connection refused", "remote", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			l.handleConn(conn, handle)
+		}()
+	}
+}
+
+// handleConn serves packages from conn until the peer closes it, sends a bad frame
+// or the listener shuts down.
+func (l *Listener) handleConn(conn net.Conn, handle func(Conn, *Package)) {
+	defer l.forget(conn)
+	defer conn.Close()
+	if m := l.peer.metrics; m != nil {
+		m.ConnOpened()
+		defer m.ConnClosed()
+	}
+	raw := conn
+	if l.encrypt {
+		var err error
+		if raw, err = secure(conn, false); err != nil {
+			l.connError(conn, err)
+			return
+		}
+	}
+	peer := withConfig(raw, l.peer)
+	var remote *Version
+	if l.version != nil {
+		var err error
+		if remote, err = acceptHandshake(peer, l.version, l.writeTimeout); err != nil {
+			l.connError(conn, err)
+			return
+		}
+	}
+	for {
+		conn.SetReadDeadline(deadline(l.readTimeout))
+		pack, err := peer.ReadPackage()
+		if errors.Is(err, ErrCodecMismatch) || errors.Is(err, ErrUnauthenticated) {
+			// answer so the peer reports the reason instead of EOF
+			conn.SetWriteDeadline(deadline(l.writeTimeout))
+			peer.WritePackage(errorPackage(&Package{}, err))
+		}
+		if err != nil {
+			l.connError(conn, err)
+			return
+		}
+		if pack.Option == OptionPing {
+			conn.SetWriteDeadline(deadline(l.writeTimeout))
+			if err := peer.WritePackage(&Package{ID: pack.ID, Option: OptionPing}); err != nil {
+				l.connError(conn, err)
+				return
+			}
+			continue
+		}
+		if l.limiter != nil && !l.limiter.allow(remoteIP(conn)) {
+			conn.SetWriteDeadline(deadline(l.writeTimeout))
+			if err := peer.WritePackage(errorPackage(pack, ErrRateLimited)); err != nil {
+				l.connError(conn, err)
+				return
+			}
+			continue
+		}
+		if !l.setState(conn, true) {
+			return
+		}
+		pack.RemoteAddr = conn.RemoteAddr().String()
+		pack.RemoteVersion = remote
+		conn.SetWriteDeadline(deadline(l.writeTimeout))
+		if err := l.call(handle, peer, pack); err != nil {
+			l.connError(conn, err)
+			return
+		}
+		if !l.setState(conn, false) {
+			return
+		}
+	}
+}
+
+// call runs handle with the package context, which is cancelled once handle returns.
+// With HandlerTimeout the handler writes through a handlerConn, so its writes are
+// dropped once it overran instead of following the timeout response. The overrunning
+// handler keeps running until it returns, Shutdown waits for it.
+func (l *Listener) call(handle func(Conn, *Package), peer *peerConn, pack *Package) error {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if l.handlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(l.ctx, l.handlerTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(l.ctx)
+	}
+	defer cancel()
+	pack.ctx = ctx
+	if l.handlerTimeout <= 0 {
+		return l.run(handle, peer, pack)
+	}
+	conn := &handlerConn{Conn: peer.Conn}
+	handler := withConfig(conn, l.peer)
+	done := make(chan error, 1)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		done <- l.run(handle, handler, pack)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	// a package the handler is writing, chunked ones included, is finished first
+	handler.wmu.Lock()
+	conn.expire()
+	handler.wmu.Unlock()
+	EventLog().Warn("network: handler timeout", "remote", pack.RemoteAddr, "option", pack.Option)
+	peer.WritePackage(errorPackage(pack, ErrHandlerTimeout))
+	return ErrHandlerTimeout
+}
+
+// run calls handle, turning a panic into ErrHandlerPanic unless RecoverPanics is off.
+func (l *Listener) run(handle func(Conn, *Package), peer Conn, pack *Package) (err error) {
+	if !l.noRecover {
+		defer func() {
+			if r := recover(); r != nil {
+				EventLog().Error("network: handler panic", "remote", pack.RemoteAddr, "option", pack.Option,
+					"err", r, "stack", string(debug.Stack()))
+				peer.WritePackage(errorPackage(pack, ErrHandlerPanic))
+				err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+			}
+		}()
+	}
+	handle(peer, pack)
+	return nil
+}
+
+// handlerConn drops the writes of a handler that overran HandlerTimeout.
+type handlerConn struct {
+	net.Conn
+	mu      sync.Mutex
+	expired bool
+}
+
+func (c *handlerConn) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expired = true
+}
+
+func (c *handlerConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expired {
+		return 0, ErrHandlerTimeout
+	}
+	return c.Conn.Write(p)
+}
+
+func (l *Listener) connError(conn net.Conn, err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	EventLog().Debug("network: connection dropped", "remote", conn.RemoteAddr().String(), "err", err)
+	if l.onConnError != nil {
+		l.onConnError(conn, err)
+	}
+}
+
+// deadline is now+d, or no deadline when d is 0.
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
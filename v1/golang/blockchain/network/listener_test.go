@@ -0,0 +1,447 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	late := make(chan error, 1)
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		<-release
+		late <- conn.WritePackage(&Package{ID: pack.ID, Option: pack.Option, Data: "late"})
+	}, HandlerTimeout(20*time.Millisecond))
+	defer close(release)
+
+	_, err := Send(address, &Package{Option: 1})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || !strings.Contains(remote.Message, ErrHandlerTimeout.Error()) {
+		t.Fatalf("got %v, want a remote ErrHandlerTimeout", err)
+	}
+	release <- struct{}{}
+	if err := <-late; !errors.Is(err, ErrHandlerTimeout) {
+		t.Fatalf("late write: got %v, want ErrHandlerTimeout", err)
+	}
+}
+
+func TestHandlerTimeoutCancelsContext(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(1, func(ctx context.Context, pack *Package) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "too slow", nil
+		}
+	})
+	mux.HandleFunc(2, func(ctx context.Context, pack *Package) (string, error) {
+		return "fast", nil
+	})
+	_, address := listen(t, mux.ServeConn, HandlerTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	if _, err := Send(address, &Package{Option: 1}); err == nil {
+		t.Fatal("cancelled handler succeeded")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("handler wasn't cancelled")
+	}
+	res, err := Send(address, &Package{Option: 2})
+	if err != nil || res.Data != "fast" {
+		t.Fatalf("got %v, %v, want fast", res, err)
+	}
+}
+
+func TestHandlerContextDoneAfterReturn(t *testing.T) {
+	contexts := make(chan context.Context, 1)
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		contexts <- pack.Context()
+		echo(conn, pack)
+	})
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-(<-contexts).Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context of a finished handler isn't done")
+	}
+}
+
+func TestShutdownWaitsForOverrunningHandler(t *testing.T) {
+	running := make(chan struct{})
+	finished := make(chan struct{})
+	l, address := listen(t, func(conn Conn, pack *Package) {
+		close(running)
+		time.Sleep(100 * time.Millisecond)
+		close(finished)
+	}, HandlerTimeout(10*time.Millisecond))
+	go Send(address, &Package{Option: 1})
+	<-running
+	l.Close()
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Close returned before the handler")
+	}
+}
+
+// The timeout response must not land in the middle of a chunked response.
+func TestHandlerTimeoutDuringChunkedWrite(t *testing.T) {
+	payload := make([]byte, 8<<20)
+	timeout := 5 * time.Millisecond
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		time.Sleep(timeout - time.Millisecond)
+		conn.WritePackage(&Package{ID: pack.ID, Option: pack.Option, Raw: payload})
+	}, HandlerTimeout(timeout), MaxPackageSize(16<<20))
+	for i := 0; i < 10; i++ {
+		c, err := Dial(address, WithMaxPackageSize(16<<20))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Send(&Package{Option: 1})
+		c.Close()
+		var remote *RemoteError
+		switch {
+		case err == nil && len(res.Raw) != len(payload):
+			t.Fatalf("got %d bytes, want %d", len(res.Raw), len(payload))
+		case err != nil && !errors.As(err, &remote):
+			t.Fatalf("response broken by the timeout: %v", err)
+		}
+	}
+}
+
+func TestListenOccupiedPort(t *testing.T) {
+	_, address := listen(t, echo)
+	l, err := Listen(address, echo)
+	if err == nil {
+		l.Close()
+		t.Fatal("second Listen on the same port succeeded")
+	}
+	if !strings.Contains(err.Error(), address) {
+		t.Fatalf("error %q doesn't name %s", err, address)
+	}
+}
+
+func TestListenBadAddress(t *testing.T) {
+	for _, address := range []string{"not-an-address", "127.0.0.1:port", "127.0.0.1:65536", "127.0.0.1:-1"} {
+		l, err := Listen(address, echo)
+		if err == nil {
+			l.Close()
+			t.Fatalf("Listen(%q) succeeded", address)
+		}
+		if !strings.Contains(err.Error(), address) {
+			t.Fatalf("Listen(%q): error %q doesn't name the address", address, err)
+		}
+	}
+}
+
+func TestSendAfterClose(t *testing.T) {
+	l, address := listen(t, echo)
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Send(address, &Package{Option: 1}); !errors.Is(err, ErrDial) {
+		t.Fatalf("got %v, want ErrDial", err)
+	}
+}
+
+func TestCloseWaitsForHandlers(t *testing.T) {
+	started := make(chan struct{})
+	finished := false
+	l, address := listen(t, func(conn Conn, pack *Package) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished = true
+		echo(conn, pack)
+	})
+	go Send(address, &Package{Option: 1})
+	<-started
+	l.Close()
+	if !finished {
+		t.Fatal("Close returned before the handler finished")
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	started := make(chan struct{})
+	l, address := listen(t, func(conn Conn, pack *Package) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		echo(conn, pack)
+	})
+	res := make(chan error, 1)
+	go func() {
+		pack, err := Send(address, &Package{Option: 1, Data: "slow"})
+		if err == nil && pack.Data != "slow" {
+			err = errors.New("wrong response " + pack.Data)
+		}
+		res <- err
+	}()
+	<-started
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- l.Shutdown(contextTimeout(t, 5*time.Second)) }()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := Send(address, &Package{Option: 1}); !errors.Is(err, ErrDial) {
+		t.Fatalf("dial during shutdown: got %v, want ErrDial", err)
+	}
+	if err := <-res; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownForceCloses(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	l, address := listen(t, func(conn Conn, pack *Package) { <-release })
+	go Send(address, &Package{Option: 1})
+	waitFor(t, func() bool { return l.Conns() == 1 })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestReadTimeoutDropsStalledClient(t *testing.T) {
+	dropped := make(chan error, 1)
+	_, address := listen(t, echo, ReadTimeout(50*time.Millisecond), OnConnError(func(_ net.Conn, err error) {
+		dropped <- err
+	}))
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the first byte of a header, then nothing
+	if _, err := conn.Write([]byte{ProtocolVersion}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-dropped:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("got %v, want a read deadline error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled client not dropped")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read %d bytes, %v from a dropped connection, want EOF", n, err)
+	}
+}
+
+func TestReadTimeoutPerPackage(t *testing.T) {
+	_, address := listen(t, echo, ReadTimeout(100*time.Millisecond))
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// each request refreshes the deadline, so the connection outlives it
+	for i := 0; i < 4; i++ {
+		if _, err := c.Send(&Package{Option: 1}); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	const n = 5
+	var running atomic.Int32
+	release := make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	defer unblock()
+	l, address := listen(t, func(conn Conn, pack *Package) {
+		running.Add(1)
+		<-release
+		echo(conn, pack)
+	}, MaxConns(n))
+
+	errs := make(chan error, n+10)
+	for i := 0; i < n+10; i++ {
+		go func() {
+			conn, err := net.Dial("tcp", address)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			peer := NewConn(conn)
+			if err := peer.WritePackage(&Package{Option: 1}); err != nil {
+				errs <- err
+				return
+			}
+			_, err = peer.ReadPackage()
+			errs <- err
+		}()
+	}
+	failed := 0
+	for failed < 10 {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Fatal("a request was answered before the handlers were released")
+			}
+			failed++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d connections refused, want 10", failed)
+		}
+	}
+	// the refusals can arrive before every accepted handler has started
+	deadline := time.Now().Add(5 * time.Second)
+	for running.Load() < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := running.Load(); got != n {
+		t.Fatalf("%d handlers running, want %d", got, n)
+	}
+	if got := l.Conns(); got != n {
+		t.Fatalf("Conns() = %d, want %d", got, n)
+	}
+	if got := l.ConnsFrom("127.0.0.1"); got != n {
+		t.Fatalf("ConnsFrom() = %d, want %d", got, n)
+	}
+	unblock()
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaxConnsPerIP(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	l, address := listen(t, func(conn Conn, pack *Package) { <-release }, MaxConnsPerIP(2))
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+	waitFor(t, func() bool { return l.Conns() == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if got := l.ConnsFrom("127.0.0.1"); got != 2 {
+		t.Fatalf("ConnsFrom() = %d, want 2", got)
+	}
+}
+
+func TestListenIPv6(t *testing.T) {
+	l, err := Listen("[::1]:0", func(conn Conn, pack *Package) {
+		conn.WritePackage(&Package{Option: pack.Option, Data: pack.RemoteAddr})
+	})
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	defer l.Close()
+	if l.Port() == 0 {
+		t.Fatal("Port() = 0 after listening on port 0")
+	}
+	address := fmt.Sprintf("[::1]:%d", l.Port())
+	if got := l.Addr().String(); got != address {
+		t.Fatalf("Addr() = %s, want %s", got, address)
+	}
+	res, err := Send(address, &Package{Option: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res.Data, "[::1]:") {
+		t.Fatalf("server saw remote %q, want [::1]", res.Data)
+	}
+}
+
+// externalIP is a non-loopback IPv4 address of the machine.
+func externalIP(t *testing.T) string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			return ipnet.IP.String()
+		}
+	}
+	t.Skip("no external IPv4 address")
+	return ""
+}
+
+func TestListenLoopbackOnly(t *testing.T) {
+	ip := externalIP(t)
+	l, _ := listen(t, echo)
+	external := net.JoinHostPort(ip, fmt.Sprint(l.Port()))
+	if _, err := SendWithTimeout(external, &Package{Option: 1}, time.Second); err == nil {
+		t.Fatalf("listener on 127.0.0.1 answered on %s", external)
+	}
+}
+
+func TestListenAllInterfaces(t *testing.T) {
+	ip := externalIP(t)
+	l, err := Listen(":0", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, host := range []string{"127.0.0.1", ip} {
+		address := net.JoinHostPort(host, fmt.Sprint(l.Port()))
+		if _, err := Send(address, &Package{Option: 1}); err != nil {
+			t.Fatalf("%s: %v", address, err)
+		}
+	}
+}
+
+// Three packages in a single write leave the next ones in the read buffer of the
+// listener, it must serve all of them and stop at the garbage that follows.
+func TestListenerServesBufferedPackages(t *testing.T) {
+	_, address := listen(t, echo)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var buf []byte
+	for i := 1; i <= 3; i++ {
+		data := []byte(SerializePackage(&Package{ID: uint64(i), Option: 1, Data: fmt.Sprint("package ", i)}))
+		buf = append(buf, frame(CodecJSON, nil, data)...)
+	}
+	buf = append(buf, "garbage, not a frame at all"...)
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	peer := NewConn(conn)
+	seen := make(map[uint64]bool)
+	for i := 0; i < 3; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		res, err := peer.ReadPackage()
+		if err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		if res.Data != fmt.Sprint("package ", res.ID) || seen[res.ID] {
+			t.Fatalf("got ID %d with %q", res.ID, res.Data)
+		}
+		seen[res.ID] = true
+	}
+	if _, err := peer.ReadPackage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("after the garbage: got %v, want the connection closed", err)
+	}
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	eventLog   atomic.Pointer[slog.Logger]
+	discardLog = slog.New(discardHandler{})
+)
+
+// EventLog receives structured diagnostics: accept errors, failed dials, connections
+// refused or dropped and why, decode failures, write errors and send timeouts. Events
+// carry the remote address as "remote" and the error as "err". The default discards
+// everything, see SetEventLog.
+func EventLog() *slog.Logger {
+	if l := eventLog.Load(); l != nil {
+		return l
+	}
+	return discardLog
+}
+
+// SetEventLog makes l the EventLog, nil discards everything again. It may be called
+// while connections are served.
+func SetEventLog(l *slog.Logger) {
+	eventLog.Store(l)
+}
+
+// Logger is a printf-style sink for the diagnostics of EventLog, see SetLogger.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// SetLogger routes EventLog to l: warnings and errors go to Errorf, the rest to Debugf.
+// A nil l discards everything again.
+func SetLogger(l Logger) {
+	if l == nil {
+		SetEventLog(nil)
+		return
+	}
+	SetEventLog(slog.New(loggerHandler{logger: l}))
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// loggerHandler formats records as "message key=value ..." for a Logger.
+type loggerHandler struct {
+	logger Logger
+	attrs  []slog.Attr
+}
+
+func (loggerHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h loggerHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	if r.Level >= slog.LevelWarn {
+		h.logger.Errorf("%s", b.String())
+	} else {
+		h.logger.Debugf("%s", b.String())
+	}
+	return nil
+}
+
+func (h loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return h
+}
+
+func (h loggerHandler) WithGroup(string) slog.Handler { return h }
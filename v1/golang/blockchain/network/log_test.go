@@ -0,0 +1,135 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capture records the messages and attributes of the events logged to it.
+type capture struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (c *capture) Enabled(context.Context, slog.Level) bool { return true }
+func (c *capture) WithAttrs([]slog.Attr) slog.Handler       { return c }
+func (c *capture) WithGroup(string) slog.Handler            { return c }
+
+func (c *capture) Handle(_ context.Context, r slog.Record) error {
+	event := r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		event += fmt.Sprintf(" %s=%v", a.Key, a.Value)
+		return true
+	})
+	c.mu.Lock()
+	c.events = append(c.events, event)
+	c.mu.Unlock()
+	return nil
+}
+
+// find waits up to a second for an event starting with prefix.
+func (c *capture) find(prefix string) (string, bool) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		for _, event := range c.events {
+			if strings.HasPrefix(event, prefix) {
+				c.mu.Unlock()
+				return event, true
+			}
+		}
+		c.mu.Unlock()
+	}
+	return "", false
+}
+
+func captureEvents(t *testing.T) *capture {
+	c := &capture{}
+	SetEventLog(slog.New(c))
+	t.Cleanup(func() { SetEventLog(nil) })
+	return c
+}
+
+func TestEventLogMalformedFrame(t *testing.T) {
+	events := captureEvents(t)
+	_, address := listen(t, echo)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := writeFull(conn, frame(JSONCodec.ID(), nil, []byte("{not json"))); err != nil {
+		t.Fatal(err)
+	}
+	event, ok := events.find("network: decode")
+	if !ok {
+		t.Fatal("no decode event")
+	}
+	if !strings.Contains(event, "remote="+conn.LocalAddr().String()) || !strings.Contains(event, "err=") {
+		t.Fatalf("event %q lacks the remote address or the error", event)
+	}
+}
+
+func TestEventLogDial(t *testing.T) {
+	events := captureEvents(t)
+	address := freeAddress(t)
+	Send(address, &Package{Option: 1})
+	if _, ok := events.find("network: dial remote=" + address); !ok {
+		t.Fatal("no dial event")
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var l printfLogger
+	SetLogger(&l)
+	defer SetLogger(nil)
+	Send(freeAddress(t), &Package{Option: 1})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errors) == 0 || !strings.HasPrefix(l.errors[0], "network: dial remote=") {
+		t.Fatalf("Errorf got %q", l.errors)
+	}
+}
+
+// Replacing the logger while connections are served must not race.
+func TestSetEventLogConcurrent(t *testing.T) {
+	_, address := listen(t, echo)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				SetEventLog(slog.New(&capture{}))
+				SetEventLog(nil)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		Send(address, &Package{Option: 1})
+		Send(freeAddress(t), &Package{Option: 1})
+	}
+	close(stop)
+	<-done
+}
+
+type printfLogger struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *printfLogger) Debugf(string, ...any) {}
+
+func (l *printfLogger) Errorf(format string, args ...any) {
+	l.mu.Lock()
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+	l.mu.Unlock()
+}
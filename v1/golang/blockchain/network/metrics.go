@@ -0,0 +1,105 @@
+package network
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+// Metrics receives network events. Listeners report through it with Observe, clients
+// with WithMetrics and Send with SendMetrics; nothing is reported when unset.
+// Byte counts are those of encoded packages. Methods may be called concurrently.
+type Metrics interface {
+	ConnOpened()
+	ConnClosed()
+	PackageReceived(option Option, bytes int)
+	PackageSent(option Option, bytes int)
+	Timeout()
+	DecodeError()
+}
+
+// SendMetrics receives the events of Send, SendContext and the other one-shot sends.
+var SendMetrics Metrics
+
+// Observe reports the listener events to m.
+func Observe(m Metrics) ListenOption {
+	return func(l *Listener) { l.peer.metrics = m }
+}
+
+// WithMetrics reports the client events to m.
+func WithMetrics(m Metrics) DialOption {
+	return func(c *Client) { c.peer.metrics = m }
+}
+
+// Counters is a Metrics counting events with atomic counters.
+type Counters struct {
+	connsOpened, connsClosed       atomic.Int64
+	packagesReceived, packagesSent atomic.Int64
+	bytesReceived, bytesSent       atomic.Int64
+	timeouts, decodeErrors         atomic.Int64
+}
+
+// CountersSnapshot holds the values of Counters at one point in time.
+type CountersSnapshot struct {
+	ConnsOpened, ConnsClosed       int64
+	PackagesReceived, PackagesSent int64
+	BytesReceived, BytesSent       int64
+	Timeouts, DecodeErrors         int64
+}
+
+func (c *Counters) ConnOpened()  { c.connsOpened.Add(1) }
+func (c *Counters) ConnClosed()  { c.connsClosed.Add(1) }
+func (c *Counters) Timeout()     { c.timeouts.Add(1) }
+func (c *Counters) DecodeError() { c.decodeErrors.Add(1) }
+
+func (c *Counters) PackageReceived(_ Option, bytes int) {
+	c.packagesReceived.Add(1)
+	c.bytesReceived.Add(int64(bytes))
+}
+
+func (c *Counters) PackageSent(_ Option, bytes int) {
+	c.packagesSent.Add(1)
+	c.bytesSent.Add(int64(bytes))
+}
+
+func (c *Counters) Snapshot() CountersSnapshot {
+	return CountersSnapshot{
+		ConnsOpened:      c.connsOpened.Load(),
+		ConnsClosed:      c.connsClosed.Load(),
+		PackagesReceived: c.packagesReceived.Load(),
+		PackagesSent:     c.packagesSent.Load(),
+		BytesReceived:    c.bytesReceived.Load(),
+		BytesSent:        c.bytesSent.Load(),
+		Timeouts:         c.timeouts.Load(),
+		DecodeErrors:     c.decodeErrors.Load(),
+	}
+}
+
+// decodeErrors are the read errors caused by bytes that aren't a valid package.
+var decodeErrors = []error{
+	ErrDeserialize, ErrCodecMismatch, ErrChecksum, ErrBadMAC, ErrUnauthenticated, ErrDecrypt,
+	ErrProtocolVersion, ErrLegacyFrame, ErrFrameFlags, ErrFrameTooLarge, ErrChunk, ErrTransferTooLarge,
+}
+
+func observeRead(m Metrics, pack *Package, size int, err error) {
+	if err == nil {
+		m.PackageReceived(pack.Option, size)
+		return
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		m.Timeout()
+		return
+	}
+	if isDecodeError(err) {
+		m.DecodeError()
+	}
+}
+
+func isDecodeError(err error) bool {
+	for _, target := range decodeErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
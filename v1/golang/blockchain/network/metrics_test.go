@@ -0,0 +1,74 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	var server, client Counters
+	_, address := listen(t, echo, Observe(&server))
+	c, err := Dial(address, WithMetrics(&client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"a", "bb", "ccc"} {
+		if _, err := c.Send(&Package{Option: 1, Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+	waitFor(t, func() bool { return server.Snapshot().ConnsClosed == 1 })
+
+	s, cs := server.Snapshot(), client.Snapshot()
+	if s.ConnsOpened != 1 || s.PackagesReceived != 3 || s.PackagesSent != 3 {
+		t.Fatalf("server counted %+v", s)
+	}
+	if cs.ConnsOpened != 1 || cs.PackagesReceived != 3 || cs.PackagesSent != 3 {
+		t.Fatalf("client counted %+v", cs)
+	}
+	if s.BytesReceived != cs.BytesSent || s.BytesSent != cs.BytesReceived || s.BytesReceived == 0 {
+		t.Fatalf("server %+v and client %+v disagree on bytes", s, cs)
+	}
+	if s.DecodeErrors != 0 || s.Timeouts != 0 {
+		t.Fatalf("server counted errors: %+v", s)
+	}
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("not a frame, not a frame, not a frame"))
+	waitFor(t, func() bool { return server.Snapshot().ConnsClosed == 2 })
+	conn.Close()
+	if s := server.Snapshot(); s.DecodeErrors != 1 {
+		t.Fatalf("garbage counted as %+v", s)
+	}
+}
+
+func TestSendMetrics(t *testing.T) {
+	var counters Counters
+	SendMetrics = &counters
+	t.Cleanup(func() { SendMetrics = nil })
+	_, address := listen(t, echo)
+	if _, err := Send(address, &Package{Option: 1, Data: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	s := counters.Snapshot()
+	if s.ConnsOpened != 1 || s.ConnsClosed != 1 || s.PackagesSent != 1 || s.PackagesReceived != 1 {
+		t.Fatalf("Send counted %+v", s)
+	}
+}
+
+func TestCountersTimeout(t *testing.T) {
+	var server Counters
+	_, address := listen(t, echo, Observe(&server), ReadTimeout(20*time.Millisecond))
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(frame(CodecJSON, nil, []byte(`{"Option":1}`))[:5])
+	waitFor(t, func() bool { return server.Snapshot().Timeouts == 1 })
+}
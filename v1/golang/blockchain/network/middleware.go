@@ -0,0 +1,32 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Logging logs the remote address, option, duration and error of every package.
+func Logging(logger *log.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, pack *Package) (string, error) {
+			start := time.Now()
+			data, err := next(ctx, pack)
+			logger.Printf("%s option=%v took=%s err=%v", pack.RemoteAddr, pack.Option, time.Since(start), err)
+			return data, err
+		}
+	}
+}
+
+// MaxPayloadSize rejects packages whose Data and Raw together exceed size bytes.
+func MaxPayloadSize(size int) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, pack *Package) (string, error) {
+			if n := len(pack.Data) + len(pack.Raw); n > size {
+				return "", fmt.Errorf("payload of %d bytes exceeds %d", n, size)
+			}
+			return next(ctx, pack)
+		}
+	}
+}
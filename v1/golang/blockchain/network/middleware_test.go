@@ -0,0 +1,64 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+// hello answers every option with a greeting.
+func hello(_ context.Context, pack *Package) (string, error) {
+	return "hello " + pack.Data, nil
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	mux := NewMux()
+	mux.Use(Logging(log.New(&buf, "", 0)))
+	mux.HandleFunc(1, hello)
+	mux.HandleFunc(2, func(context.Context, *Package) (string, error) {
+		return "", errors.New("refused")
+	})
+	_, address := listen(t, mux.ServeConn)
+
+	if _, err := Send(address, &Package{Option: 1, Data: "peer"}); err != nil {
+		t.Fatal(err)
+	}
+	Send(address, &Package{Option: 2})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "127.0.0.1:") || !strings.Contains(lines[0], "option=Option(1)") || !strings.HasSuffix(lines[0], "err=<nil>") {
+		t.Fatalf("unexpected log line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "err=refused") {
+		t.Fatalf("unexpected log line %q", lines[1])
+	}
+}
+
+func TestMaxPayloadSizeMiddleware(t *testing.T) {
+	called := 0
+	mux := NewMux()
+	mux.Use(MaxPayloadSize(8))
+	mux.HandleFunc(1, func(ctx context.Context, pack *Package) (string, error) {
+		called++
+		return hello(ctx, pack)
+	})
+	_, address := listen(t, mux.ServeConn)
+
+	if res, err := Send(address, &Package{Option: 1, Data: "peer"}); err != nil || res.Data != "hello peer" {
+		t.Fatalf("small package: got %v, %v", res, err)
+	}
+	var remote *RemoteError
+	_, err := Send(address, &Package{Option: 1, Data: "peer", Raw: []byte("12345")})
+	if !errors.As(err, &remote) || !strings.Contains(remote.Message, "exceeds 8") {
+		t.Fatalf("large package: got %v, want a remote error", err)
+	}
+	if called != 1 {
+		t.Fatalf("handler called %d times, want 1", called)
+	}
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// OptionError is the Option of a response to a package no handler could serve,
+// Data carries the reason.
+const OptionError Option = -1
+
+// HandlerFunc builds the response Data for a package, ctx is Package.Context.
+type HandlerFunc func(ctx context.Context, pack *Package) (string, error)
+
+// Middleware wraps a handler, it may short-circuit by returning an error without calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Mux dispatches packages to the handler registered for their Option.
+type Mux struct {
+	mu          sync.RWMutex
+	handlers    map[Option]HandlerFunc
+	middlewares []Middleware
+}
+
+func NewMux() *Mux {
+	return &Mux{handlers: make(map[Option]HandlerFunc)}
+}
+
+// HandleFunc registers fn for option, it panics if option already has a handler.
+func (m *Mux) HandleFunc(option Option, fn HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.handlers[option]; ok {
+		panic(fmt.Sprintf("network: multiple registrations for option %v", option))
+	}
+	m.handlers[option] = fn
+}
+
+// Use appends mw to the chain run for every package, the first one added runs outermost.
+func (m *Mux) Use(mw Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.middlewares = append(m.middlewares, mw)
+}
+
+// ServeConn writes the response of the handler registered for pack.Option,
+// it has the Listen handle signature so a Mux can be passed to Listen directly.
+// Unknown options and handler errors are answered with an OptionError package.
+// A response that can't be written closes conn, the peer may have got part of it.
+func (m *Mux) ServeConn(conn Conn, pack *Package) {
+	m.mu.RLock()
+	fn, ok := m.handlers[pack.Option]
+	if !ok {
+		fn = unknownOption
+	}
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		fn = m.middlewares[i](fn)
+	}
+	m.mu.RUnlock()
+	data, err := fn(pack.Context(), pack)
+	res := &Package{ID: pack.ID, Option: pack.Option, Data: data}
+	if err != nil {
+		res = errorPackage(pack, err)
+	}
+	if err := conn.WritePackage(res); err != nil {
+		conn.Close()
+	}
+}
+
+func unknownOption(_ context.Context, pack *Package) (string, error) {
+	return "", fmt.Errorf("unknown option %v", pack.Option)
+}
+
+// RemoteError is a request failure reported by the peer, as opposed to failing
+// to reach it or to read its response.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "network: peer: " + e.Message
+}
+
+// errorPackage answers req with err, Data repeats the reason for peers predating Package.Error.
+func errorPackage(req *Package, err error) *Package {
+	return &Package{ID: req.ID, Option: OptionError, Data: err.Error(), Error: err.Error()}
+}
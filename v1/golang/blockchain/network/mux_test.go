@@ -0,0 +1,144 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(1, func(_ context.Context, pack *Package) (string, error) {
+		return "hello " + pack.Data, nil
+	})
+	mux.HandleFunc(2, func(context.Context, *Package) (string, error) {
+		return "", errors.New("no such block")
+	})
+	_, address := listen(t, mux.ServeConn)
+
+	res, err := Send(address, &Package{Option: 1, Data: "peer"})
+	if err != nil || res.Data != "hello peer" || res.Option != 1 {
+		t.Fatalf("got %+v, %v", res, err)
+	}
+	var remote *RemoteError
+	if _, err := Send(address, &Package{Option: 2}); !errors.As(err, &remote) || remote.Message != "no such block" {
+		t.Fatalf("handler error: got %v", err)
+	}
+	if _, err := Send(address, &Package{Option: 3}); !errors.As(err, &remote) || !strings.Contains(remote.Message, "unknown option") {
+		t.Fatalf("unknown option: got %v", err)
+	}
+}
+
+func TestMuxDuplicateRegistration(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) { return "", nil })
+	defer func() {
+		if recover() == nil {
+			t.Fatal("second registration didn't panic")
+		}
+	}()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) { return "", nil })
+}
+
+func TestMuxMiddlewareOrder(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	call := func(name string) {
+		mu.Lock()
+		calls = append(calls, name)
+		mu.Unlock()
+	}
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, pack *Package) (string, error) {
+				call(name)
+				return next(ctx, pack)
+			}
+		}
+	}
+	mux := NewMux()
+	mux.Use(trace("outer"))
+	mux.Use(trace("inner"))
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) {
+		call("handler")
+		return "", nil
+	})
+	_, address := listen(t, mux.ServeConn)
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"outer", "inner", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls %v, want %v", calls, want)
+	}
+}
+
+func TestMuxClosesConnOnWriteError(t *testing.T) {
+	server, client := net.Pipe()
+	client.Close()
+	mux := NewMux()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) { return "lost", nil })
+	conn := withConfig(server, defaultConnConfig)
+	mux.ServeConn(conn, &Package{Option: 1})
+	if _, err := server.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("conn still open after a failed write: %v", err)
+	}
+}
+
+func TestPackageErr(t *testing.T) {
+	tests := []struct {
+		pack *Package
+		want string // "" for no error
+	}{
+		{&Package{Option: 1, Data: "ok"}, ""},
+		{&Package{Option: OptionError, Data: "old peer", Error: "old peer"}, "old peer"},
+		{&Package{Option: OptionError, Data: "from Data"}, "from Data"}, // peers predating Error
+		{&Package{Option: 1, Error: "failed"}, "failed"},
+	}
+	for _, tt := range tests {
+		err := tt.pack.Err()
+		var remote *RemoteError
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%+v: got %v, want nil", tt.pack, err)
+		case tt.want != "" && (!errors.As(err, &remote) || remote.Message != tt.want):
+			t.Errorf("%+v: got %v, want a RemoteError %q", tt.pack, err, tt.want)
+		}
+	}
+	if s := SerializePackage(&Package{Option: 1}); strings.Contains(s, "Error") {
+		t.Fatalf("a package without error serializes as %s", s)
+	}
+}
+
+func TestRemoteErrorOverClient(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) {
+		return "", errors.New("invalid transaction")
+	})
+	_, address := listen(t, mux.ServeConn)
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var remote *RemoteError
+	if _, err := c.Send(&Package{Option: 1}); !errors.As(err, &remote) || remote.Message != "invalid transaction" {
+		t.Fatalf("got %v, want RemoteError invalid transaction", err)
+	}
+	// the connection survives a failed request
+	if _, err := c.Send(&Package{Option: 1}); !errors.As(err, &remote) {
+		t.Fatalf("second request: got %v", err)
+	}
+	// transport failures are not remote errors
+	if _, err := Send(freeAddress(t), &Package{Option: 1}); errors.As(err, &remote) || !errors.Is(err, ErrDial) {
+		t.Fatalf("dial failure: got %v", err)
+	}
+}
@@ -1,93 +1,280 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
-	"strings"
+	"os"
+	"sync/atomic"
 	"time"
 )
 
+// Option selects what a package asks for. Negative values are reserved for the
+// network package, values from 16 for the blockchain protocol; it is sent as a number.
+type Option int
+
+func (o Option) String() string {
+	if name, ok := optionNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Option(%d)", int(o))
+}
+
 type Package struct {
-	Option int
+	ID     uint64 `json:",omitempty"` // pairs responses with requests on a persistent Client
+	Option Option
 	Data   string
+	Raw    []byte `json:",omitempty"` // binary payload, sent base64 encoded
+	Chunk  int    `json:",omitempty"` // index of an OptionChunk package in its transfer
+	Chunks int    `json:",omitempty"` // number of chunks in the transfer
+	Relay  string `json:",omitempty"` // listen address of the node relaying a gossiped package
+	Error  string `json:",omitempty"` // why the request failed, set on OptionError responses
+
+	RemoteAddr    string   `json:"-"` // sender address, set on the server side
+	RemoteVersion *Version `json:"-"` // sender version, set on listeners using Handshake
+
+	ctx context.Context
+}
+
+// Context of the request being served, done once its handler returns, the
+// HandlerTimeout of the listener passes or Shutdown gives up waiting. It is
+// context.Background for packages not received by a Listener.
+func (p *Package) Context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// NewBytesPackage makes a package carrying binary data in Raw.
+func NewBytesPackage(option Option, data []byte) *Package {
+	return &Package{Option: option, Raw: data}
+}
+
+// Err returns a *RemoteError when pack is an OptionError response, nil otherwise.
+// Peers predating Package.Error send the reason in Data only.
+func (p *Package) Err() error {
+	if p.Error != "" {
+		return &RemoteError{Message: p.Error}
+	}
+	if p.Option == OptionError {
+		return &RemoteError{Message: p.Data}
+	}
+	return nil
+}
+
+// Bytes returns Raw when set, otherwise Data as bytes.
+func (p *Package) Bytes() []byte {
+	if p.Raw != nil {
+		return p.Raw
+	}
+	return []byte(p.Data)
 }
 
 const (
-	EndBytes = "\000\005\007\001\001\007\005\000"
 	WaitTime = 5
-	DMaxSize = 2 << 20 // 2 * 2^20 = 2MiB
-	BuffSize = 4 << 10 // 4 * 2^10 = 4 Kib
+	DMaxSize = 2 << 20 // 2 * 2^20 = 2MiB, default MaxPackageSize
+	BuffSize = 4 << 10 // 4 * 2^10 = 4 Kib, default ReadBufferSize
+
+	MinPackageSize = 1 << 10 // lowest MaxPackageSize accepted
+	MaxDataSize    = MaxTransferSize
+
+	lowestOption = OptionPing // the lowest reserved Option
 )
 
-type Listener net.Listener
-type Conn net.Conn
+var (
+	ErrDial          = errors.New("network: dial failed")
+	ErrTimeout       = errors.New("network: timeout")
+	ErrDeserialize   = errors.New("network: malformed package")
+	ErrCodecMismatch = errors.New("network: peer uses a different codec")
+	ErrInvalidOption = errors.New("network: invalid option")
 
-// Listen address ip:port
-func Listen(address string, handle func(Conn, *Package)) Listener {
-	splitted := strings.Split(address, ":")
-	if len(splitted) != 2 {
-		return nil
-	}
-	listener, err := net.Listen("tcp", "0.0.0.0:"+splitted[1])
-	if err != nil {
-		return nil
-	}
-	go serve(listener, handle)
-	return Listener(listener)
+	// Malformed packages also match ErrDeserialize.
+	ErrUnknownField = errors.New("network: unknown package field")
+	ErrDataTooLarge = errors.New("network: package Data exceeds MaxDataSize")
+	ErrOptionRange  = errors.New("network: package Option out of range")
+)
+
+// Conn is a connection packages are exchanged on with the codec and limits of its
+// listener or client. WritePackage may be called from several goroutines, the frames
+// of concurrent writers never interleave. Raw Writes bypass that and must not be mixed in.
+type Conn interface {
+	net.Conn
+	WritePackage(*Package) error
+	ReadPackage() (*Package, error)
+}
+
+// NewConn makes conn a Conn with the settings of Send: JSONCodec, DMaxSize and SendMetrics.
+func NewConn(conn net.Conn) Conn {
+	return newSendConn(conn)
 }
 
-func Handle(option int, conn Conn, pack *Package, handle func(p *Package) string) bool {
+func newSendConn(conn net.Conn) *peerConn {
+	cfg := defaultConnConfig
+	cfg.metrics = SendMetrics
+	return withConfig(conn, cfg)
+}
+
+// Handle answers pack with the Data built by handle when pack has option and reports
+// whether it did. err is set when the response couldn't be written in full.
+func Handle(option Option, conn Conn, pack *Package, handle func(p *Package) string) (bool, error) {
 	if option != pack.Option {
-		return false
+		return false, nil
 	}
-	conn.Write([]byte(SerializePackage(&Package{Option: option, Data: handle(pack)}) + EndBytes))
-	return true
+	return true, conn.WritePackage(&Package{ID: pack.ID, Option: option, Data: handle(pack)})
 }
-func serve(listener net.Listener, handle func(Conn, *Package)) {
-	defer listener.Close()
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			break
-		}
-		go handleConn(conn, handle)
+
+// Send package to address and wait WaitTime seconds for the response.
+// A peer failing the request is reported as a *RemoteError.
+func Send(address string, pack *Package) (*Package, error) {
+	return SendWithTimeout(address, pack, WaitTime*time.Second)
+}
+
+// SendWithTimeout package to address, timeout covers both dial and read phases.
+func SendWithTimeout(address string, pack *Package, timeout time.Duration) (*Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return SendContext(ctx, address, pack)
+}
+
+// SendContext package to address, ctx cancellation and deadline abort both dial and read phases.
+func SendContext(ctx context.Context, address string, pack *Package) (*Package, error) {
+	return send(ctx, sharedSendSlots(), DefaultDialer.DialContext, address, pack)
+}
+
+// DefaultMaxConcurrentSends limits how many connections Send and the functions built
+// on it have open at once, further sends queue for a slot within their own timeout.
+// SetMaxConcurrentSends changes it, Broadcast may use its own limit, see MaxConcurrentSends.
+const DefaultMaxConcurrentSends = 32
+
+var (
+	defaultSendSlots = newSendSlots(DefaultMaxConcurrentSends)
+	sendSlotsSet     atomic.Pointer[sendSlots]
+)
+
+// SetMaxConcurrentSends limits Send, SendTLS, SendWS and the functions built on them
+// to n connections open at once across the process, 0 means no limit. Sends already
+// holding a slot keep it, the new limit applies to the sends starting after the call.
+func SetMaxConcurrentSends(n int) {
+	slots := newSendSlots(n)
+	sendSlotsSet.Store(&slots)
+}
+
+// sharedSendSlots are the slots of the package-level senders.
+func sharedSendSlots() sendSlots {
+	if slots := sendSlotsSet.Load(); slots != nil {
+		return *slots
 	}
+	return defaultSendSlots
 }
 
-func handleConn(conn net.Conn, handle func(Conn, *Package)) {
-	defer conn.Close()
-	pack := readPackage(conn)
-	if pack == nil {
-		return
+// sendSlots bounds concurrent sends, nil means no limit.
+type sendSlots chan struct{}
+
+func newSendSlots(n int) sendSlots {
+	if n <= 0 {
+		return nil
+	}
+	return make(sendSlots, n)
+}
+
+// acquire waits for a free slot until ctx is done, release returns it.
+func (s sendSlots) acquire(ctx context.Context) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	handle(conn, pack)
 }
-func Send(address string, pack *Package) *Package {
-	conn, err := net.Dial("tcp", address)
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func send(ctx context.Context, slots sendSlots, dial dialFunc, address string, pack *Package) (*Package, error) {
+	release, err := slots.acquire(ctx)
 	if err != nil {
-		fmt.Println("Error open connect")
-		return nil
+		return nil, sendTimeout(ctx, address, pack)
 	}
-	//fmt.Println("Connect is open")
+	defer release()
+	network, addr := splitAddress(address)
+	raw, err := dial(ctx, network, addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, sendTimeout(ctx, address, pack)
+		}
+		EventLog().Error("network: dial", "remote", address, "err", err)
+		return nil, fmt.Errorf("%w: %s: %w", ErrDial, address, err)
+	}
+	conn := newSendConn(raw)
 	defer conn.Close()
-	conn.Write([]byte(SerializePackage(pack) + EndBytes))
-	var (
-		res = new(Package)
-		ch  = make(chan bool)
-	)
-	go func() {
-		res = readPackage(conn)
-		ch <- true
-	}()
-	select {
-	case <-ch:
-	case <-time.After(WaitTime * time.Second):
+	if m := SendMetrics; m != nil {
+		m.ConnOpened()
+		defer m.ConnClosed()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// closing conn unblocks the read as soon as ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := conn.WritePackage(pack); err != nil {
+		if ctx.Err() != nil {
+			return nil, sendTimeout(ctx, address, pack)
+		}
+		return nil, fmt.Errorf("network: write to %s: %w", address, err)
 	}
-	return res
+	res, err := conn.ReadPackage()
+	switch {
+	case err == nil:
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case ctx.Err() != nil:
+		return nil, sendTimeout(ctx, address, pack)
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		EventLog().Info("network: send timeout", "remote", address, "option", pack.Option)
+		return nil, fmt.Errorf("%w: %s", ErrTimeout, address)
+	case errors.Is(err, ErrDeserialize):
+		return nil, fmt.Errorf("%w: %s", err, address)
+	default:
+		return nil, fmt.Errorf("network: read from %s: %w", address, err)
+	}
+}
+
+// sendTimeout is contextError, logging the deadline case.
+func sendTimeout(ctx context.Context, address string, pack *Package) error {
+	err := contextError(ctx, address)
+	if errors.Is(err, ErrTimeout) {
+		EventLog().Info("network: send timeout", "remote", address, "option", pack.Option)
+	}
+	return err
+}
+
+func contextError(ctx context.Context, address string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s: %w", ErrTimeout, address, ctx.Err())
+	}
+	return fmt.Errorf("network: send to %s: %w", address, ctx.Err())
 }
 
 func SerializePackage(pack *Package) string {
+	jsonData, err := json.Marshal(*pack)
+	if err != nil {
+		return ""
+	}
+	return string(jsonData)
+}
+
+// SerializePackagePretty is the indented form of SerializePackage for logs and debugging.
+func SerializePackagePretty(pack *Package) string {
 	jsonData, err := json.MarshalIndent(*pack, "", "\t")
 	if err != nil {
 		return ""
@@ -95,38 +282,102 @@ func SerializePackage(pack *Package) string {
 	return string(jsonData)
 }
 
+// DeserializePackage decodes data like JSONCodec does, nil when it is malformed.
 func DeserializePackage(data string) *Package {
-	//fmt.Printf("DeserializePackage %s ...\n", data)
-	var pack Package
-	err := json.Unmarshal([]byte(data), &pack)
+	pack, err := decode(JSONCodec, []byte(data))
 	if err != nil {
 		return nil
 	}
-	return &pack
+	return pack
+}
+
+// checkPackage rejects decoded packages no peer sends: an Option below the reserved
+// ones or beyond 32 bits, Data over MaxDataSize or negative chunk numbers.
+func checkPackage(pack *Package) error {
+	switch {
+	case pack.Option < lowestOption || pack.Option > math.MaxInt32:
+		return fmt.Errorf("%w: %w: %d", ErrDeserialize, ErrOptionRange, int64(pack.Option))
+	case len(pack.Data) > MaxDataSize:
+		return fmt.Errorf("%w: %w: %d bytes", ErrDeserialize, ErrDataTooLarge, len(pack.Data))
+	case pack.Chunk < 0 || pack.Chunks < 0:
+		return fmt.Errorf("%w: negative chunk %d of %d", ErrDeserialize, pack.Chunk, pack.Chunks)
+	}
+	return nil
+}
+
+// writePackage writes pack as a single length-prefixed frame in the codec of conn,
+// or as a chunked transfer when it is larger than the chunk size. After an error the
+// peer may have received part of a frame, so the connection must be dropped.
+func writePackage(conn *peerConn, pack *Package) error {
+	cfg := conn.cfg
+	data, err := cfg.codec.Marshal(pack)
+	if err != nil {
+		EventLog().Error("network: encode", "option", pack.Option, "err", err)
+		return err
+	}
+	conn.wmu.Lock()
+	defer conn.wmu.Unlock()
+	if len(data) > cfg.chunkSize() {
+		err = writeChunked(conn, cfg, pack.ID, data)
+	} else {
+		err = writeFull(conn, frame(cfg.codec.ID(), cfg.key, data))
+	}
+	if err != nil {
+		EventLog().Warn("network: write", "remote", conn.RemoteAddr().String(), "option", pack.Option, "err", err)
+		return err
+	}
+	if cfg.metrics != nil {
+		cfg.metrics.PackageSent(pack.Option, len(data))
+	}
+	return nil
 }
 
-func readPackage(conn net.Conn) *Package {
-	var (
-		size   = uint64(0)
-		buffer = make([]byte, BuffSize)
-		data   string
-	)
-	for {
-		length, err := conn.Read(buffer)
-		//fmt.Printf("Read %d bytes\n", length)
+// writeFull writes all of buf, a writer accepting nothing without an error
+// fails with io.ErrShortWrite instead of looping.
+func writeFull(conn net.Conn, buf []byte) error {
+	for len(buf) > 0 {
+		n, err := conn.Write(buf)
 		if err != nil {
-			return nil
+			return err
 		}
-		size += uint64(length)
-		if size > DMaxSize {
-			return nil
-		}
-		data = string(buffer[:length])
-		//fmt.Printf("Got data %s bytes\n", data)
-		if strings.Contains(data, EndBytes) {
-			data = strings.Split(data, EndBytes)[0]
-			break
+		if n == 0 {
+			return io.ErrShortWrite
 		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+func readPackage(conn *peerConn) (*Package, error) {
+	cfg := conn.cfg
+	pack, size, err := readSingle(conn.reader(), cfg)
+	if err == nil && pack.Option == OptionChunk {
+		pack, size, err = readChunked(conn, cfg, pack)
+	}
+	if err == nil && len(pack.Data)+len(pack.Raw) > cfg.maxSize {
+		pack, err = nil, fmt.Errorf("%w: %d bytes of Data and Raw, limit %d",
+			ErrTransferTooLarge, len(pack.Data)+len(pack.Raw), cfg.maxSize)
+	}
+	if cfg.metrics != nil {
+		observeRead(cfg.metrics, pack, size, err)
+	}
+	if err != nil && isDecodeError(err) {
+		EventLog().Warn("network: decode", "remote", conn.RemoteAddr().String(), "err", err)
+	}
+	return pack, err
+}
+
+// readSingle reads one frame, not following chunked transfers.
+// The size is that of the encoded package.
+func readSingle(r io.Reader, cfg connConfig) (*Package, int, error) {
+	codec := cfg.codec
+	id, data, err := readFrame(r, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if id != codec.ID() {
+		return nil, 0, fmt.Errorf("%w: got %d, want %d", ErrCodecMismatch, id, codec.ID())
 	}
-	return DeserializePackage(data)
+	pack, err := decode(codec, data)
+	return pack, len(data), err
 }
@@ -0,0 +1,254 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendLargePackage(t *testing.T) {
+	_, address := listen(t, echo)
+	data := strings.Repeat("0123456789abcdef", 100<<10/16)
+	res, err := Send(address, &Package{Option: 1, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Option != 1 || res.Data != data {
+		t.Fatalf("got option %d and %d bytes, want 1 and %d bytes", res.Option, len(res.Data), len(data))
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	silent := func(conn net.Conn) { io.Copy(io.Discard, conn) }
+	late := func(conn net.Conn) {
+		peer := NewConn(conn)
+		pack, err := peer.ReadPackage()
+		if err != nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		peer.WritePackage(pack)
+	}
+	for name, serve := range map[string]func(net.Conn){"never": silent, "late": late} {
+		_, address := listenRaw(t, serve)
+		res, err := SendWithTimeout(address, &Package{Option: 1}, 20*time.Millisecond)
+		if !errors.Is(err, ErrTimeout) || res != nil {
+			t.Fatalf("%s: got %v, %v, want nil, ErrTimeout", name, res, err)
+		}
+	}
+}
+
+func TestSendContextCancel(t *testing.T) {
+	closed := make(chan struct{})
+	_, address := listenRaw(t, func(conn net.Conn) {
+		if _, err := NewConn(conn).ReadPackage(); err != nil {
+			return
+		}
+		io.Copy(io.Discard, conn) // until the client closes
+		close(closed)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := SendContext(ctx, address, &Package{Option: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %v", elapsed)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection still open after cancel")
+	}
+}
+
+func TestListenContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l, err := ListenContext(ctx, "127.0.0.1:0", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	if _, err := Send(address, &Package{Option: 1}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	})
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after cancel: got %v, want net.ErrClosed", err)
+	}
+	if _, err := Send(address, &Package{Option: 1}); !errors.Is(err, ErrDial) {
+		t.Fatalf("Send after cancel: got %v, want ErrDial", err)
+	}
+}
+
+// Close unregisters the listener from a ctx that outlives it.
+func TestListenContextClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l, err := ListenContext(ctx, "127.0.0.1:0", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if l.stop() {
+		t.Fatal("the ctx still refers to the closed listener")
+	}
+}
+
+func TestBytesPackage(t *testing.T) {
+	data := []byte("\x00bin\x00" + oldEndBytes + "\xff\xfe")
+	pack := NewBytesPackage(1, data)
+	got := DeserializePackage(SerializePackage(pack))
+	if got == nil || !bytes.Equal(got.Bytes(), data) {
+		t.Fatalf("got %v, want %q", got, data)
+	}
+	if s := (&Package{Option: 1, Data: "text"}).Bytes(); string(s) != "text" {
+		t.Fatalf("Bytes of a Data package: got %q", s)
+	}
+
+	_, address := listen(t, echo)
+	res, err := Send(address, pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.Bytes(), data) {
+		t.Fatalf("got %q back, want %q", res.Bytes(), data)
+	}
+}
+
+func benchmarkSerialize(b *testing.B, pack *Package) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if DeserializePackage(SerializePackage(pack)) == nil {
+			b.Fatal("round trip failed")
+		}
+	}
+}
+
+func BenchmarkSerializeString(b *testing.B) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	benchmarkSerialize(b, &Package{Option: 1, Data: hex.EncodeToString(data)})
+}
+
+func BenchmarkSerializeBytes(b *testing.B) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	benchmarkSerialize(b, NewBytesPackage(1, data))
+}
+
+func TestSerializeCompact(t *testing.T) {
+	pack := &Package{Option: 1, Data: strings.Repeat("x", 10<<10)}
+	compact, pretty := SerializePackage(pack), SerializePackagePretty(pack)
+	if strings.ContainsAny(compact, "\n\t") {
+		t.Fatalf("compact form has whitespace: %.40q", compact)
+	}
+	if len(compact) >= len(pretty) {
+		t.Fatalf("compact %d bytes, pretty %d", len(compact), len(pretty))
+	}
+	for name, s := range map[string]string{"compact": compact, "pretty": pretty} {
+		got := DeserializePackage(s)
+		if got == nil || got.Option != pack.Option || got.Data != pack.Data {
+			t.Fatalf("%s form doesn't round-trip", name)
+		}
+	}
+}
+
+// A package larger than BuffSize arrives in several reads and must be reassembled whole.
+func TestSendMultiRead(t *testing.T) {
+	data := strings.Repeat("abcdefgh", 8<<10/8)
+	_, address := listen(t, echo, ReadBufferSize(512))
+	c, err := Dial(address, WithReadBufferSize(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Send(&Package{Option: 1, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != data {
+		t.Fatalf("got %d bytes back, want %d", len(res.Data), len(data))
+	}
+
+	// the frame trickling in 1000 bytes at a time
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer client.Close()
+		buf := frame(CodecJSON, nil, []byte(SerializePackage(&Package{Option: 1, Data: data})))
+		for len(buf) > 0 {
+			n := min(1000, len(buf))
+			client.Write(buf[:n])
+			buf = buf[n:]
+		}
+	}()
+	pack, err := NewConn(server).ReadPackage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Data != data {
+		t.Fatalf("reassembled %d bytes, want %d", len(pack.Data), len(data))
+	}
+}
+
+// Peers before compact serialization sent MarshalIndent output like this.
+func TestDeserializeIndented(t *testing.T) {
+	old := "{\n\t\"Option\": 1,\n\t\"Data\": \"block\"\n}"
+	got := DeserializePackage(old)
+	if got == nil || got.Option != 1 || got.Data != "block" {
+		t.Fatalf("got %+v", got)
+	}
+	pack := &Package{ID: 7, Option: 3, Data: "x", Raw: []byte{0, 1}, Error: "e"}
+	compact, pretty := DeserializePackage(SerializePackage(pack)), DeserializePackage(SerializePackagePretty(pack))
+	if !reflect.DeepEqual(compact, pretty) || !reflect.DeepEqual(compact, pack) {
+		t.Fatalf("compact %+v, indented %+v, want %+v", compact, pretty, pack)
+	}
+}
+
+func benchmarkSerializeForms(b *testing.B, pack *Package) {
+	for _, form := range []struct {
+		name      string
+		serialize func(*Package) string
+	}{
+		{"compact", SerializePackage},
+		{"indented", SerializePackagePretty},
+	} {
+		serialize := form.serialize
+		b.Run(form.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(serialize(pack))))
+			for i := 0; i < b.N; i++ {
+				if DeserializePackage(serialize(pack)) == nil {
+					b.Fatal("round trip failed")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSerializeSmall(b *testing.B) {
+	benchmarkSerializeForms(b, &Package{Option: 1, Data: "42"})
+}
+
+func BenchmarkSerializeBlock(b *testing.B) {
+	benchmarkSerializeForms(b, &Package{Option: 1, Data: blockPayload(930)}) // about 1MiB
+}
@@ -0,0 +1,23 @@
+package network
+
+// Options of the blockchain protocol, served by BlockChain.Register in the blockchain
+// package. Values below 16 are left to applications.
+const (
+	OptionGetBlock    Option = 16 + iota // Data is the block index, the response Data the JSON block
+	OptionGetLastHash                    // response Data is the hex tip hash
+	OptionPushBlock                      // Data is a JSON block extending the tip, the response Data the new height
+	OptionGetHeight                      // response Data is the number of blocks
+	OptionGetPeers                       // response Data is a JSON array of peer addresses, see PeerManager.Register
+)
+
+var optionNames = map[Option]string{
+	OptionError:       "OptionError",
+	OptionHandshake:   "OptionHandshake",
+	OptionChunk:       "OptionChunk",
+	OptionPing:        "OptionPing",
+	OptionGetBlock:    "OptionGetBlock",
+	OptionGetLastHash: "OptionGetLastHash",
+	OptionPushBlock:   "OptionPushBlock",
+	OptionGetHeight:   "OptionGetHeight",
+	OptionGetPeers:    "OptionGetPeers",
+}
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandlerPanicRecovered(t *testing.T) {
+	events := captureEvents(t)
+	mux := NewMux()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) {
+		var m map[string]int
+		m["boom"]++ // nil map
+		return "", nil
+	})
+	mux.HandleFunc(2, func(_ context.Context, pack *Package) (string, error) {
+		return "healthy " + pack.Data, nil
+	})
+	_, address := listen(t, mux.ServeConn)
+
+	var remote *RemoteError
+	if _, err := Send(address, &Package{Option: 1}); !errors.As(err, &remote) || !strings.Contains(remote.Message, ErrHandlerPanic.Error()) {
+		t.Fatalf("got %v, want a RemoteError for ErrHandlerPanic", err)
+	}
+	event, ok := events.find("network: handler panic")
+	if !ok {
+		t.Fatal("no panic event")
+	}
+	if !strings.Contains(event, "assignment to entry in nil map") || !strings.Contains(event, "panic_test.go") {
+		t.Fatalf("event lacks the panic or its stack: %.200s", event)
+	}
+	res, err := Send(address, &Package{Option: 2, Data: "node"})
+	if err != nil || res.Data != "healthy node" {
+		t.Fatalf("after the panic: got %v, %v", res, err)
+	}
+}
+
+func TestHandlerPanicClosesConn(t *testing.T) {
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		if pack.Option == 1 {
+			panic("boom")
+		}
+		echo(conn, pack)
+	})
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send(&Package{Option: 1}); err == nil {
+		t.Fatal("panicking handler answered")
+	}
+	waitFor(t, func() bool { return c.State() == StateClosed })
+}
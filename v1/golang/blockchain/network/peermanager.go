@@ -0,0 +1,241 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoPeers = errors.New("network: no known peers")
+
+// DefaultMaxFailures is the MaxFailures of a PeerManager made by NewPeerManager.
+const DefaultMaxFailures = 3
+
+// PeerInfo is what a PeerManager knows about a peer.
+type PeerInfo struct {
+	Address  string
+	LastSeen time.Time     // last successful request
+	Failures int           `json:",omitempty"` // failed requests since the last success
+	Latency  time.Duration `json:",omitempty"` // round trip of the last successful request
+}
+
+// PeerManager tracks known peers and how well they answer, safe for concurrent use.
+// A peer is dropped after MaxFailures failures in a row, 0 keeps failing peers.
+type PeerManager struct {
+	MaxFailures int
+	MaxPeers    int // cap on peers learned from other nodes, 0 means no cap
+
+	mu    sync.Mutex
+	peers map[string]*PeerInfo
+}
+
+func NewPeerManager() *PeerManager {
+	return &PeerManager{
+		MaxFailures: DefaultMaxFailures,
+		MaxPeers:    DefaultMaxPeers,
+		peers:       make(map[string]*PeerInfo),
+	}
+}
+
+// LoadPeerManager reads peers saved by Save, a missing file gives an empty manager.
+func LoadPeerManager(path string) (*PeerManager, error) {
+	pm := NewPeerManager()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pm, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []PeerInfo
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrDeserialize, path, err)
+	}
+	for i := range list {
+		pm.peers[list[i].Address] = &list[i]
+	}
+	return pm, nil
+}
+
+// Save writes the peers to path as JSON, replacing the file atomically.
+func (pm *PeerManager) Save(path string) error {
+	data, err := json.MarshalIndent(pm.Peers(), "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Add address, keeping what is known about it already.
+func (pm *PeerManager) Add(address string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if _, ok := pm.peers[address]; !ok {
+		pm.peers[address] = &PeerInfo{Address: address}
+	}
+}
+
+func (pm *PeerManager) Remove(address string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	delete(pm.peers, address)
+}
+
+// Good records a request to address answered in latency, 0 keeps the last latency.
+func (pm *PeerManager) Good(address string, latency time.Duration) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	info, ok := pm.peers[address]
+	if !ok {
+		return
+	}
+	info.LastSeen = time.Now()
+	info.Failures = 0
+	if latency > 0 {
+		info.Latency = latency
+	}
+}
+
+// Bad records a failed request to address, dropping the peer at MaxFailures.
+func (pm *PeerManager) Bad(address string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	info, ok := pm.peers[address]
+	if !ok {
+		return
+	}
+	info.Failures++
+	if pm.MaxFailures > 0 && info.Failures >= pm.MaxFailures {
+		delete(pm.peers, address)
+	}
+}
+
+// Len returns the number of known peers.
+func (pm *PeerManager) Len() int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return len(pm.peers)
+}
+
+// Peers returns a copy of every peer, sorted by address.
+func (pm *PeerManager) Peers() []PeerInfo {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	list := make([]PeerInfo, 0, len(pm.peers))
+	for _, info := range pm.peers {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+// List returns the addresses sorted.
+func (pm *PeerManager) List() []string {
+	return addresses(pm.Peers())
+}
+
+// Random returns up to n addresses picked at random.
+func (pm *PeerManager) Random(n int) []string {
+	list := pm.Peers()
+	rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	return addresses(list[:min(n, len(list))])
+}
+
+// Best returns up to n addresses, peers with fewer failures first and among them
+// the lowest latency. Peers never measured come after measured ones.
+func (pm *PeerManager) Best(n int) []string {
+	list := pm.Peers()
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Failures != b.Failures {
+			return a.Failures < b.Failures
+		}
+		if (a.Latency == 0) != (b.Latency == 0) {
+			return a.Latency != 0
+		}
+		return a.Latency < b.Latency
+	})
+	return addresses(list[:min(n, len(list))])
+}
+
+func addresses(list []PeerInfo) []string {
+	res := make([]string, len(list))
+	for i, info := range list {
+		res[i] = info.Address
+	}
+	return res
+}
+
+// Send pack to address and record the outcome and latency.
+func (pm *PeerManager) Send(address string, pack *Package) (*Package, error) {
+	start := time.Now()
+	res, err := Send(address, pack)
+	pm.record(address, err, time.Since(start))
+	return res, err
+}
+
+// record the outcome of a request to address, a *RemoteError still means the peer answered.
+func (pm *PeerManager) record(address string, err error, latency time.Duration) {
+	if unreachable(err) {
+		pm.Bad(address)
+	} else {
+		pm.Good(address, latency)
+	}
+}
+
+// unreachable reports whether err means no response arrived from the peer.
+func unreachable(err error) bool {
+	var remote *RemoteError
+	return err != nil && !errors.As(err, &remote)
+}
+
+// Broadcast pack to every known peer like Broadcast and record the outcomes.
+func (pm *PeerManager) Broadcast(pack *Package, opts ...BroadcastOption) map[string]Result {
+	res := Broadcast(pm.List(), pack, opts...)
+	for address, r := range res {
+		pm.record(address, r.Err, 0)
+	}
+	return res
+}
+
+// Register answers OptionGetPeers with a random sample of up to SharedPeers
+// peers that answered their last request.
+func (pm *PeerManager) Register(mux *Mux) {
+	mux.HandleFunc(OptionGetPeers, func(context.Context, *Package) (string, error) {
+		data, err := json.Marshal(pm.sample(SharedPeers))
+		return string(data), err
+	})
+}
+
+// Bootstrap asks address for its peers and adds them and address itself,
+// self is the own address of the node and is skipped.
+func (pm *PeerManager) Bootstrap(address, self string) error {
+	start := time.Now()
+	list, err := fetchPeers(address)
+	if err != nil {
+		return err
+	}
+	pm.Add(address)
+	pm.Good(address, time.Since(start))
+	pm.merge(list, self)
+	return nil
+}
@@ -0,0 +1,137 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPeerManagerDropsUnreachablePeer(t *testing.T) {
+	pm := NewPeerManager()
+	pm.MaxFailures = 2
+	address := freeAddress(t)
+	pm.Add(address)
+	for i := 0; i < 2; i++ {
+		if _, err := pm.Send(address, &Package{Option: 1}); err == nil {
+			t.Fatal("send to a closed port succeeded")
+		}
+	}
+	if pm.Len() != 0 {
+		t.Fatalf("%v still known after MaxFailures failures", pm.List())
+	}
+}
+
+func TestPeerManagerKeepsPeerReportingErrors(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(1, func(context.Context, *Package) (string, error) {
+		return "", errors.New("busy")
+	})
+	_, address := listen(t, mux.ServeConn)
+	pm := NewPeerManager()
+	pm.MaxFailures = 1
+	pm.Add(address)
+	var remote *RemoteError
+	if _, err := pm.Send(address, &Package{Option: 1}); !errors.As(err, &remote) {
+		t.Fatalf("got %v, want a RemoteError", err)
+	}
+	peers := pm.Peers()
+	if len(peers) != 1 || peers[0].Failures != 0 || peers[0].LastSeen.IsZero() {
+		t.Fatalf("got %+v, want a peer that answered", peers)
+	}
+}
+
+func TestPeerManagerBootstrap(t *testing.T) {
+	remote := NewPeerManager()
+	for _, address := range []string{"10.0.0.1:8080", "10.0.0.2:8080", "self.example:8080", "not an address"} {
+		remote.Add(address)
+	}
+	mux := NewMux()
+	remote.Register(mux)
+	_, address := listen(t, mux.ServeConn)
+
+	pm := NewPeerManager()
+	if err := pm.Bootstrap(address, "self.example:8080"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:8080", "10.0.0.2:8080", address}
+	if got := pm.List(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPeerManagerBest(t *testing.T) {
+	pm := NewPeerManager()
+	pm.MaxFailures = 0
+	for _, address := range []string{"a:1", "b:1", "c:1", "d:1"} {
+		pm.Add(address)
+	}
+	pm.Good("a:1", 30*time.Millisecond)
+	pm.Good("b:1", 10*time.Millisecond)
+	pm.Bad("c:1")
+	want := []string{"b:1", "a:1", "d:1", "c:1"}
+	if got := pm.Best(4); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPeerManagerSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	pm := NewPeerManager()
+	pm.Add("10.0.0.1:8080")
+	pm.Good("10.0.0.1:8080", time.Millisecond)
+	pm.Add("10.0.0.2:8080")
+	if err := pm.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPeerManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := loaded.Peers(), pm.Peers()
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d peers, saved %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Address != want[i].Address || !got[i].LastSeen.Equal(want[i].LastSeen) || got[i].Latency != want[i].Latency {
+			t.Fatalf("loaded %+v, saved %+v", got, want)
+		}
+	}
+	if empty, err := LoadPeerManager(filepath.Join(t.TempDir(), "missing.json")); err != nil || empty.Len() != 0 {
+		t.Fatalf("missing file: %v peers, %v", empty.Len(), err)
+	}
+}
+
+// peerNode listens with a PeerManager answering OptionGetPeers.
+func peerNode(t *testing.T) (*PeerManager, string) {
+	t.Helper()
+	pm := NewPeerManager()
+	mux := NewMux()
+	pm.Register(mux)
+	_, address := listen(t, mux.ServeConn)
+	return pm, address
+}
+
+func TestPeerDiscovery(t *testing.T) {
+	a, addressA := peerNode(t)
+	b, addressB := peerNode(t)
+	_, addressC := peerNode(t)
+	b.Add(addressC)
+	b.Add(addressA)
+	a.Add(addressB)
+
+	if added := a.ExchangeOnce(addressA); added != 1 {
+		t.Fatalf("added %d peers, want 1", added)
+	}
+	want := []string{addressB, addressC}
+	sort.Strings(want)
+	if got := a.List(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("A knows %v, want %v", got, want)
+	}
+	if res, err := a.Send(addressC, &Package{Option: OptionGetPeers}); err != nil || res.Option != OptionGetPeers {
+		t.Fatalf("A can't reach C: %v, %v", res, err)
+	}
+}
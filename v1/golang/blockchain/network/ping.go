@@ -0,0 +1,83 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OptionPing packages are answered by the listener itself with an OptionPing
+// package of the same ID, handlers never see them.
+const OptionPing Option = -4
+
+var ErrPeerDead = errors.New("network: peer stopped answering pings")
+
+// Ping address and return the round-trip time.
+func Ping(address string) (time.Duration, error) {
+	start := time.Now()
+	res, err := Send(address, &Package{Option: OptionPing})
+	if err != nil {
+		return 0, err
+	}
+	if err := pong(res); err != nil {
+		return 0, fmt.Errorf("%w: %s", err, address)
+	}
+	return time.Since(start), nil
+}
+
+// Ping the peer over the client connection and return the round-trip time.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	res, err := c.SendContext(ctx, &Package{Option: OptionPing})
+	if err != nil {
+		return 0, err
+	}
+	if err := pong(res); err != nil {
+		return 0, fmt.Errorf("%w: %s", err, c.address)
+	}
+	return time.Since(start), nil
+}
+
+func pong(res *Package) error {
+	if res.Option != OptionPing {
+		return fmt.Errorf("network: unexpected ping response: %v %q", res.Option, res.Data)
+	}
+	return nil
+}
+
+// WithKeepalive pings the peer whenever nothing was received for interval, and closes
+// the connection with ErrPeerDead after missed pings in a row went unanswered.
+// Each ping may take interval to be answered.
+func WithKeepalive(interval time.Duration, missed int) DialOption {
+	return func(c *Client) { c.keepalive, c.maxMissed = interval, missed }
+}
+
+// keepaliveLoop watches conn until done is closed.
+func (c *Client) keepaliveLoop(conn *peerConn, done chan struct{}) {
+	ticker := time.NewTicker(c.keepalive)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastSeen.Load())) < c.keepalive {
+			missed = 0
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.keepalive)
+		_, err := c.Ping(ctx)
+		cancel()
+		if err == nil {
+			missed = 0
+			continue
+		}
+		if missed++; missed >= c.maxMissed {
+			c.fail(conn, fmt.Errorf("%w: %s", ErrPeerDead, c.address))
+			return
+		}
+	}
+}
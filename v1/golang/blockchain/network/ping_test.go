@@ -0,0 +1,64 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	var handled atomic.Bool
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		handled.Store(true)
+		echo(conn, pack)
+	})
+	rtt, err := Ping(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt <= 0 || rtt > time.Second {
+		t.Fatalf("loopback round trip of %v", rtt)
+	}
+	if handled.Load() {
+		t.Fatal("the handler saw the ping")
+	}
+
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepaliveDetectsDeadPeer(t *testing.T) {
+	_, address := listenRaw(t, func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	c, err := Dial(address, WithKeepalive(20*time.Millisecond, 2), WithReadTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitFor(t, func() bool { return c.State() == StateClosed })
+	if _, err := c.Send(&Package{Option: 1}); !errors.Is(err, ErrPeerDead) {
+		t.Fatalf("got %v, want ErrPeerDead", err)
+	}
+}
+
+func TestKeepaliveHealthyPeer(t *testing.T) {
+	_, address := listen(t, echo)
+	c, err := Dial(address, WithKeepalive(10*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	time.Sleep(100 * time.Millisecond)
+	if res, err := c.Send(&Package{Option: 1, Data: "alive"}); err != nil || res.Data != "alive" {
+		t.Fatalf("got %v, %v", res, err)
+	}
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pool keeps one Client per address so repeated sends to a peer reuse its connection.
+// A client whose connection failed is replaced on the next send.
+type Pool struct {
+	opts []DialOption
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewPool makes a pool dialing with opts.
+func NewPool(opts ...DialOption) *Pool {
+	return &Pool{opts: opts, clients: make(map[string]*Client)}
+}
+
+// Send pack to address and wait WaitTime seconds for the response.
+func (p *Pool) Send(address string, pack *Package) (*Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+	defer cancel()
+	return p.SendContext(ctx, address, pack)
+}
+
+// SendContext pack to address over the pooled connection, dialing it if needed.
+func (p *Pool) SendContext(ctx context.Context, address string, pack *Package) (*Package, error) {
+	c, err := p.client(address)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendContext(ctx, pack)
+}
+
+func (p *Pool) client(address string) (*Client, error) {
+	p.mu.Lock()
+	c := p.live(address)
+	p.mu.Unlock()
+	if c != nil {
+		return c, nil
+	}
+	// dial unlocked, a slow peer mustn't hold up the sends to the others
+	c, err := Dial(address, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if other := p.live(address); other != nil {
+		// another send dialed address meanwhile
+		c.Close()
+		return other, nil
+	}
+	p.clients[address] = c
+	return c, nil
+}
+
+// live returns the pooled client of address unless its connection failed, a failed
+// one is dropped. p.mu must be held.
+func (p *Pool) live(address string) *Client {
+	c, ok := p.clients[address]
+	if !ok {
+		return nil
+	}
+	if c.closeErr() == nil {
+		return c
+	}
+	c.Close()
+	delete(p.clients, address)
+	return nil
+}
+
+// Close every pooled connection.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for address, c := range p.clients {
+		c.Close()
+		delete(p.clients, address)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+// A dial hanging on one peer must not hold up the sends to the others.
+func TestPoolSlowDial(t *testing.T) {
+	_, good := listen(t, echo)
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	useDialer(t, dialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "blackhole:1" {
+			close(dialing)
+			<-release
+			return nil, errors.New("unreachable")
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}))
+	p := NewPool()
+	defer p.Close()
+	done := make(chan error)
+	go func() {
+		_, err := p.Send("blackhole:1", &Package{Option: 1})
+		done <- err
+	}()
+	<-dialing
+	res, err := p.Send(good, &Package{Option: 1, Data: "fast"})
+	close(release)
+	if err != nil || res.Data != "fast" {
+		t.Fatalf("send while another dial hangs: got %+v, %v", res, err)
+	}
+	if err := <-done; !errors.Is(err, ErrDial) {
+		t.Fatalf("blackholed peer: got %v, want ErrDial", err)
+	}
+}
+
+// Sends dialing the same peer at once end up sharing one connection.
+func TestPoolConcurrentDials(t *testing.T) {
+	l, address := listen(t, echo)
+	p := NewPool()
+	defer p.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Send(address, &Package{Option: 1}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	p.mu.Lock()
+	pooled := len(p.clients)
+	p.mu.Unlock()
+	if pooled != 1 {
+		t.Fatalf("%d clients pooled, want 1", pooled)
+	}
+	waitFor(t, func() bool { return l.Conns() == 1 })
+}
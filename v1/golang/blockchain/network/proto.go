@@ -0,0 +1,149 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ProtoCodec encodes packages in the protobuf wire format of package.proto,
+// so peers written in other languages can use generated code for it. The schema
+// is normative, this encoder follows it without depending on a protobuf runtime.
+var ProtoCodec Codec = protoCodec{}
+
+// Protobuf field numbers and wire types of package.proto.
+const (
+	protoFieldID     = 1
+	protoFieldOption = 2
+	protoFieldData   = 3
+	protoFieldRaw    = 4
+	protoFieldChunk  = 5
+	protoFieldChunks = 6
+	protoFieldRelay  = 7
+	protoFieldError  = 8
+
+	protoVarint = 0
+	protoI64    = 1
+	protoBytes  = 2
+	protoI32    = 5
+)
+
+type protoCodec struct{}
+
+func (protoCodec) ID() byte { return CodecProto }
+
+// Marshal skips zero fields as proto3 does.
+func (protoCodec) Marshal(pack *Package) ([]byte, error) {
+	var buf []byte
+	if pack.ID != 0 {
+		buf = protoAppendTag(buf, protoFieldID, protoVarint)
+		buf = binary.AppendUvarint(buf, pack.ID)
+	}
+	if pack.Option != 0 {
+		buf = protoAppendTag(buf, protoFieldOption, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(int64(pack.Option)))
+	}
+	if pack.Data != "" {
+		buf = protoAppendTag(buf, protoFieldData, protoBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(pack.Data)))
+		buf = append(buf, pack.Data...)
+	}
+	if len(pack.Raw) > 0 {
+		buf = protoAppendTag(buf, protoFieldRaw, protoBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(pack.Raw)))
+		buf = append(buf, pack.Raw...)
+	}
+	if pack.Chunk != 0 {
+		buf = protoAppendTag(buf, protoFieldChunk, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(int64(pack.Chunk)))
+	}
+	if pack.Chunks != 0 {
+		buf = protoAppendTag(buf, protoFieldChunks, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(int64(pack.Chunks)))
+	}
+	if pack.Relay != "" {
+		buf = protoAppendTag(buf, protoFieldRelay, protoBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(pack.Relay)))
+		buf = append(buf, pack.Relay...)
+	}
+	if pack.Error != "" {
+		buf = protoAppendTag(buf, protoFieldError, protoBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(pack.Error)))
+		buf = append(buf, pack.Error...)
+	}
+	return buf, nil
+}
+
+// Unmarshal skips unknown fields so newer peers may extend the schema.
+func (protoCodec) Unmarshal(data []byte) (*Package, error) {
+	pack := new(Package)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, protoError("bad tag")
+		}
+		data = data[n:]
+		field, wire := tag>>3, tag&7
+		var value []byte
+		var number uint64
+		switch wire {
+		case protoVarint:
+			number, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, protoError("bad varint")
+			}
+		case protoBytes:
+			size, m := binary.Uvarint(data)
+			if m <= 0 || size > uint64(len(data)-m) {
+				return nil, protoError("bad length")
+			}
+			value, n = data[m:m+int(size)], m+int(size)
+		case protoI64:
+			n = 8
+		case protoI32:
+			n = 4
+		default:
+			return nil, protoError(fmt.Sprintf("wire type %d", wire))
+		}
+		if n > len(data) {
+			return nil, protoError("truncated field")
+		}
+		data = data[n:]
+		switch {
+		case field == protoFieldID && wire == protoVarint:
+			pack.ID = number
+		case field == protoFieldOption && wire == protoVarint:
+			pack.Option = Option(int64(number))
+		case field == protoFieldData && wire == protoBytes:
+			if !utf8.Valid(value) {
+				return nil, protoError("data is not utf-8")
+			}
+			pack.Data = string(value)
+		case field == protoFieldRaw && wire == protoBytes:
+			pack.Raw = append([]byte(nil), value...)
+		case field == protoFieldChunk && wire == protoVarint:
+			pack.Chunk = int(int64(number))
+		case field == protoFieldChunks && wire == protoVarint:
+			pack.Chunks = int(int64(number))
+		case field == protoFieldRelay && wire == protoBytes:
+			if !utf8.Valid(value) {
+				return nil, protoError("relay is not utf-8")
+			}
+			pack.Relay = string(value)
+		case field == protoFieldError && wire == protoBytes:
+			if !utf8.Valid(value) {
+				return nil, protoError("error is not utf-8")
+			}
+			pack.Error = string(value)
+		}
+	}
+	return pack, nil
+}
+
+func protoAppendTag(buf []byte, field, wire uint64) []byte {
+	return binary.AppendUvarint(buf, field<<3|wire)
+}
+
+func protoError(reason string) error {
+	return fmt.Errorf("%w: protobuf: %s", ErrDeserialize, reason)
+}
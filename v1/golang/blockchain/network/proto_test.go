@@ -0,0 +1,161 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONNodeRejectsProto(t *testing.T) {
+	_, address := listen(t, echo)
+	c, err := Dial(address, WithCodec(ProtoCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send(&Package{Option: OptionGetBlock, Data: "0"}); !errors.Is(err, ErrCodecMismatch) {
+		t.Fatalf("got %v, want ErrCodecMismatch", err)
+	}
+}
+
+func TestProtoSkipsUnknownFields(t *testing.T) {
+	data, err := ProtoCodec.Marshal(&Package{Option: OptionGetHeight, Data: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// field 15 of every wire type a newer peer might add
+	data = append(data, 15<<3|protoVarint, 1)
+	data = append(data, 15<<3|protoBytes, 2, 'a', 'b')
+	data = append(data, 15<<3|protoI64, 0, 0, 0, 0, 0, 0, 0, 0)
+	data = append(data, 15<<3|protoI32, 0, 0, 0, 0)
+	pack, err := ProtoCodec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Option != OptionGetHeight || pack.Data != "x" {
+		t.Fatalf("got %+v", pack)
+	}
+}
+
+func TestProtoMalformed(t *testing.T) {
+	valid, _ := ProtoCodec.Marshal(&Package{Option: 1, Data: "data"})
+	for name, data := range map[string][]byte{
+		"truncated":  valid[:len(valid)-1],
+		"bad length": {protoFieldData<<3 | protoBytes, 100, 'a'},
+		"not utf-8":  {protoFieldData<<3 | protoBytes, 1, 0xff},
+		"wire type":  {protoFieldData<<3 | 3},
+		"bad varint": bytes.Repeat([]byte{0xff}, 11),
+	} {
+		if _, err := ProtoCodec.Unmarshal(data); !errors.Is(err, ErrDeserialize) {
+			t.Errorf("%s: got %v, want ErrDeserialize", name, err)
+		}
+	}
+}
+
+// protoGolden is the encoding package.proto gives protoGoldenPackage: fields in
+// number order, the negative option as a ten byte varint.
+const protoGolden = "0807" + // id: 7
+	"10ffffffffffffffffff01" + // option: -1
+	"1a0568656c6c6f" + // data: "hello"
+	"2203000102" + // raw: "\x00\x01\x02"
+	"2802" + // chunk: 2
+	"3005" + // chunks: 5
+	"3a0d31302e302e302e313a38303830" + // relay: "10.0.0.1:8080"
+	"4204626f6f6d" // error: "boom"
+
+var protoGoldenPackage = &Package{
+	ID: 7, Option: OptionError, Data: "hello", Raw: []byte{0, 1, 2},
+	Chunk: 2, Chunks: 5, Relay: "10.0.0.1:8080", Error: "boom",
+}
+
+func TestProtoGolden(t *testing.T) {
+	want, _ := hex.DecodeString(protoGolden)
+	got, err := ProtoCodec.Marshal(protoGoldenPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Marshal:\ngot  %x\nwant %x", got, want)
+	}
+	pack, err := ProtoCodec.Unmarshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pack, protoGoldenPackage) {
+		t.Fatalf("Unmarshal: got %+v, want %+v", pack, protoGoldenPackage)
+	}
+}
+
+// package.proto is normative, proto.go must follow its field numbers and types and
+// the Option values must match its enum.
+func TestProtoSchema(t *testing.T) {
+	schema, err := os.ReadFile("package.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := regexp.MustCompile(`(?s)message Package \{(.*?)\}`).FindSubmatch(schema)
+	enum := regexp.MustCompile(`(?s)enum Option \{(.*?)\}`).FindSubmatch(schema)
+	if message == nil || enum == nil {
+		t.Fatal("package.proto lacks the Package message or the Option enum")
+	}
+
+	wireTypes := map[string]uint64{"uint64": protoVarint, "int64": protoVarint, "string": protoBytes, "bytes": protoBytes}
+	type field struct{ number, wire uint64 }
+	fields := make(map[string]field)
+	for _, m := range regexp.MustCompile(`(\w+) (\w+) = (\d+);`).FindAllSubmatch(message[1], -1) {
+		wire, ok := wireTypes[string(m[1])]
+		if !ok {
+			t.Fatalf("field %s has type %s, proto.go only encodes %v", m[2], m[1], wireTypes)
+		}
+		number, _ := strconv.ParseUint(string(m[3]), 10, 64)
+		fields[string(m[2])] = field{number, wire}
+	}
+	want := map[string]field{
+		"id":     {protoFieldID, protoVarint},
+		"option": {protoFieldOption, protoVarint},
+		"data":   {protoFieldData, protoBytes},
+		"raw":    {protoFieldRaw, protoBytes},
+		"chunk":  {protoFieldChunk, protoVarint},
+		"chunks": {protoFieldChunks, protoVarint},
+		"relay":  {protoFieldRelay, protoBytes},
+		"error":  {protoFieldError, protoBytes},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("package.proto fields %v, proto.go encodes %v", fields, want)
+	}
+	// every field sent on the wire is in the schema
+	typ := reflect.TypeOf(Package{})
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.IsExported() && f.Tag.Get("json") != "-" {
+			if _, ok := fields[strings.ToLower(f.Name)]; !ok {
+				t.Errorf("Package.%s is not in package.proto", f.Name)
+			}
+		}
+	}
+
+	values := make(map[string]Option)
+	for _, m := range regexp.MustCompile(`(\w+) = (-?\d+);`).FindAllSubmatch(enum[1], -1) {
+		value, _ := strconv.Atoi(string(m[2]))
+		values[string(m[1])] = Option(value)
+	}
+	upper := regexp.MustCompile(`[A-Z]`)
+	for option, name := range optionNames {
+		// OptionGetLastHash is OPTION_GET_LAST_HASH
+		enumName := strings.ToUpper(upper.ReplaceAllString(name, "_$0"))[1:]
+		if value, ok := values[enumName]; !ok || value != option {
+			t.Errorf("%s = %d in package.proto, want %s = %d", enumName, value, enumName, option)
+		}
+		delete(values, enumName)
+	}
+	delete(values, "OPTION_UNSPECIFIED")
+	for name := range values {
+		t.Errorf("package.proto has %s, which is not an Option", name)
+	}
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("network: rate limit exceeded")
+
+// RateLimit allows each remote IP rate packages per second on average with bursts of
+// up to burst packages. Packages over the limit are answered with an OptionError
+// package without running the handler.
+func RateLimit(rate float64, burst int) ListenOption {
+	return func(l *Listener) { l.limiter = newRateLimiter(rate, burst) }
+}
+
+// rateLimiter is a token bucket per IP. A bucket that refilled completely is
+// the same as a new one, so such buckets are dropped in a periodic sweep.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// fill is the time an empty bucket takes to refill.
+func (r *rateLimiter) fill() time.Duration {
+	return time.Duration(r.burst / r.rate * float64(time.Second))
+}
+
+func (r *rateLimiter) allow(ip string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if now.Sub(r.lastSweep) > max(r.fill(), time.Minute) {
+		r.sweep(now)
+	}
+	b, ok := r.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[ip] = b
+	}
+	b.tokens = min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (r *rateLimiter) sweep(now time.Time) {
+	for ip, b := range r.buckets {
+		if now.Sub(b.last) >= r.fill() {
+			delete(r.buckets, ip)
+		}
+	}
+	r.lastSweep = now
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	var handled atomic.Int32
+	_, address := listen(t, func(conn Conn, pack *Package) {
+		handled.Add(1)
+		echo(conn, pack)
+	}, RateLimit(10, 20))
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ok, limited := 0, 0
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		_, err := c.Send(&Package{Option: 1})
+		var remote *RemoteError
+		switch {
+		case err == nil:
+			ok++
+		case errors.As(err, &remote) && strings.Contains(err.Error(), ErrRateLimited.Error()):
+			limited++
+		default:
+			t.Fatal(err)
+		}
+	}
+	// the burst plus what refilled at 10/s while sending
+	if most := 20 + int(time.Since(start).Seconds()*10) + 1; ok < 20 || ok > most {
+		t.Fatalf("%d requests passed, want 20 to %d", ok, most)
+	}
+	if limited != 100-ok || int(handled.Load()) != ok {
+		t.Fatalf("%d limited and %d handled of %d passed", limited, handled.Load(), ok)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	r := newRateLimiter(1000, 1)
+	r.allow("10.0.0.1")
+	r.allow("10.0.0.2")
+	r.lastSweep = time.Now().Add(-2 * time.Minute)
+	for _, b := range r.buckets {
+		b.last = b.last.Add(-time.Second)
+	}
+	r.allow("10.0.0.3")
+	if len(r.buckets) != 1 {
+		t.Fatalf("%d buckets left, want only the new one", len(r.buckets))
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, opt := range []ListenOption{RateLimit(0, 1), RateLimit(1, 0)} {
+		if _, err := Listen("127.0.0.1:0", echo, opt); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("got %v, want ErrInvalidOption", err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures SendRetry and WithReconnect. MaxAttempts bounds the number
+// of attempts, 0 means no limit. The delay before attempt n+1 is BaseDelay*2^(n-1)
+// capped at MaxDelay, then randomized by up to Jitter (0..1) of itself.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration // 0 means no cap
+	Jitter      float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.2,
+}
+
+// SendRetry sends pack like Send, retrying dial failures, timeouts and responses
+// corrupted in transit (ErrChecksum) per policy. A malformed response is never retried.
+// With MaxAttempts 0 it retries until the send succeeds or fails for another reason.
+func SendRetry(address string, pack *Package, policy RetryPolicy) (*Package, error) {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		var res *Package
+		res, err = Send(address, pack)
+		if err == nil {
+			return res, nil
+		}
+		if !retryable(err) || policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			break
+		}
+		time.Sleep(policy.delay(attempt))
+	}
+	return nil, fmt.Errorf("network: gave up after %d attempts: %w", attempt, err)
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrDial) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrChecksum)
+}
+
+func (policy RetryPolicy) delay(attempt int) time.Duration {
+	shift := min(attempt-1, 62)
+	d := policy.BaseDelay << shift
+	if d>>shift != policy.BaseDelay {
+		d = math.MaxInt64
+	}
+	if policy.MaxDelay > 0 && d > policy.MaxDelay {
+		d = policy.MaxDelay
+	}
+	if policy.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * policy.Jitter * float64(d))
+		if d < 0 {
+			d = math.MaxInt64
+		}
+	}
+	return d
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendRetryWaitsForListener(t *testing.T) {
+	address := freeAddress(t)
+	dials := 0
+	useDialer(t, dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if dials == 3 {
+			l, err := Listen(address, echo)
+			if err != nil {
+				return nil, err
+			}
+			t.Cleanup(func() { l.Close() })
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}))
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	res, err := SendRetry(address, &Package{Option: 1, Data: "hello"}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "hello" || dials != 3 {
+		t.Fatalf("got %q after %d dials, want hello after 3", res.Data, dials)
+	}
+}
+
+func TestSendRetryGivesUp(t *testing.T) {
+	address := freeAddress(t)
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	_, err := SendRetry(address, &Package{Option: 1}, policy)
+	if !errors.Is(err, ErrDial) {
+		t.Fatalf("got %v, want ErrDial", err)
+	}
+	if want := "gave up after 3 attempts"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("%q doesn't report %q", err, want)
+	}
+}
+
+func TestSendRetryMalformedResponse(t *testing.T) {
+	_, address := listenRaw(t, func(conn net.Conn) {
+		conn.Write([]byte("not a frame at all, not a frame at all"))
+	})
+	dials := 0
+	useDialer(t, dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}))
+	_, err := SendRetry(address, &Package{Option: 1}, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	if err == nil || dials != 1 {
+		t.Fatalf("got %v after %d dials, want one failed attempt", err, dials)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 1, time.Second},
+		{RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 4, 8 * time.Second},
+		{RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 10, time.Minute},
+		{RetryPolicy{BaseDelay: time.Second}, 10, 512 * time.Second},
+		{RetryPolicy{BaseDelay: time.Second}, 100, math.MaxInt64},
+		{RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.delay(tt.attempt); got != tt.want {
+			t.Errorf("%+v attempt %d: delay %s, want %s", tt.policy, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := policy.delay(3); d < 2*time.Second || d > 6*time.Second {
+			t.Fatalf("delay %s outside 4s±50%%", d)
+		}
+	}
+}
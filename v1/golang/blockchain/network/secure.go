@@ -0,0 +1,163 @@
+package network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted connections start with the client, then the listener, sending an
+// ephemeral X25519 public key.
+// HKDF-SHA256 over the shared secret yields one AES-256-GCM key per direction, and the
+// byte stream is then sent as records: ciphertext length (4 bytes, big-endian) | ciphertext.
+// Record nonces are a per-direction counter, so a replayed, reordered or altered record
+// fails to open. Peers aren't authenticated, combine with AuthKey for that.
+const maxRecordSize = 64 << 10
+
+var ErrDecrypt = errors.New("network: encrypted record rejected")
+
+var secureInfo = []byte("blockchain/network encryption v1")
+
+// Encrypt makes the listener accept only encrypted connections, see WithEncryption.
+func Encrypt() ListenOption {
+	return func(l *Listener) { l.encrypt = true }
+}
+
+// WithEncryption encrypts the connection, for listeners using Encrypt.
+func WithEncryption() DialOption {
+	return func(c *Client) { c.encrypt = true }
+}
+
+type secureConn struct {
+	net.Conn
+	wmu     sync.Mutex
+	send    cipher.AEAD
+	sendSeq uint64
+	recv    cipher.AEAD
+	recvSeq uint64
+	pending []byte // decrypted bytes not read yet
+}
+
+// secure runs the key exchange on conn within HandshakeTimeout. The dialing side
+// is the client, the keys differ per direction.
+func secure(conn net.Conn, client bool) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	local := private.PublicKey().Bytes()
+	remote := make([]byte, len(local))
+	// the client sends first, unbuffered conns like net.Pipe would block both writes
+	exchange := []func() error{
+		func() error { _, err := conn.Write(local); return err },
+		func() error { _, err := io.ReadFull(conn, remote); return err },
+	}
+	if !client {
+		exchange[0], exchange[1] = exchange[1], exchange[0]
+	}
+	for _, step := range exchange {
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+		}
+	}
+	peer, err := ecdh.X25519().NewPublicKey(remote)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	secret, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+	salt := append(local, remote...)
+	if !client {
+		salt = append(remote, local...)
+	}
+	keys := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, secureInfo), keys); err != nil {
+		return nil, err
+	}
+	toServer, err := newGCM(keys[:32])
+	if err != nil {
+		return nil, err
+	}
+	toClient, err := newGCM(keys[32:])
+	if err != nil {
+		return nil, err
+	}
+	if client {
+		return &secureConn{Conn: conn, send: toServer, recv: toClient}, nil
+	}
+	return &secureConn{Conn: conn, send: toClient, recv: toServer}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *secureConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), maxRecordSize)
+		record := make([]byte, 4, 4+n+c.send.Overhead())
+		record = c.send.Seal(record, nonce(c.send, c.sendSeq), p[:n], nil)
+		binary.BigEndian.PutUint32(record, uint32(len(record)-4))
+		c.sendSeq++
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (c *secureConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(c.Conn, size[:]); err != nil {
+			return 0, err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxRecordSize+uint32(c.recv.Overhead()) {
+			return 0, fmt.Errorf("%w: %d byte record", ErrDecrypt, n)
+		}
+		record := make([]byte, n)
+		if _, err := io.ReadFull(c.Conn, record); err != nil {
+			return 0, err
+		}
+		plain, err := c.recv.Open(record[:0], nonce(c.recv, c.recvSeq), record, nil)
+		if err != nil {
+			return 0, ErrDecrypt
+		}
+		c.recvSeq++
+		c.pending = plain
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func nonce(aead cipher.AEAD, seq uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], seq)
+	return nonce
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+// tapConn records what is written to the wire and may alter it.
+type tapConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written bytes.Buffer
+	tamper  func(offset int, p []byte)
+}
+
+func (c *tapConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	if c.tamper != nil {
+		p = bytes.Clone(p)
+		c.tamper(c.written.Len(), p)
+	}
+	c.written.Write(p)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+// securePipe runs the key exchange over net.Pipe, the client side writes through tap.
+func securePipe(t *testing.T, tap *tapConn) (client, server *peerConn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() { a.Close(); b.Close() })
+	tap.Conn = a
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := secure(b, false)
+		done <- result{conn, err}
+	}()
+	c, err := secure(tap, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	return withConfig(c, defaultConnConfig), withConfig(res.conn, defaultConnConfig)
+}
+
+func TestSecureConnHidesPlaintext(t *testing.T) {
+	tap := &tapConn{}
+	client, server := securePipe(t, tap)
+	go client.WritePackage(&Package{Option: 1, Data: `{"secret":"attack at dawn"}`})
+	pack, err := server.ReadPackage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Data != `{"secret":"attack at dawn"}` {
+		t.Fatalf("got %q", pack.Data)
+	}
+	tap.mu.Lock()
+	defer tap.mu.Unlock()
+	for _, plain := range []string{"secret", "attack at dawn", `"Option"`, `"Data"`} {
+		if bytes.Contains(tap.written.Bytes(), []byte(plain)) {
+			t.Errorf("%q is readable on the wire", plain)
+		}
+	}
+}
+
+func TestSecureConnRejectsTampering(t *testing.T) {
+	keySize := 32
+	tap := &tapConn{tamper: func(offset int, p []byte) {
+		// flip a ciphertext byte of the first record after the key exchange
+		if i := keySize + 4 + 2 - offset; offset >= keySize && i >= 0 && i < len(p) {
+			p[i] ^= 1
+		}
+	}}
+	client, server := securePipe(t, tap)
+	go client.WritePackage(&Package{Option: 1, Data: "hello"})
+	if _, err := server.ReadPackage(); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptedListener(t *testing.T) {
+	_, address := listen(t, echo, Encrypt())
+	c, err := Dial(address, WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Send(&Package{Option: 1, Data: "hello"})
+	if err != nil || res.Data != "hello" {
+		t.Fatalf("got %v, %v, want hello", res, err)
+	}
+}
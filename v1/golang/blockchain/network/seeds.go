@@ -0,0 +1,66 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+)
+
+// SeedResolver looks up the hostnames passed to ResolveSeeds.
+var SeedResolver = net.DefaultResolver
+
+// ErrNoSeeds is returned by ResolveSeeds when the seeds resolved to no address.
+var ErrNoSeeds = errors.New("network: no seed resolved")
+
+// ResolveSeeds resolves the A and AAAA records of each seed hostname and returns the
+// distinct addresses as host:port in random order. A seed may carry its own port as
+// host:port. Seeds failing to resolve are skipped, ErrNoSeeds is returned when no
+// address is left, wrapping the lookup errors if any.
+func ResolveSeeds(ctx context.Context, seeds []string, port string) ([]string, error) {
+	var (
+		res  []string
+		seen = make(map[string]bool)
+		errs []error
+	)
+	for _, seed := range seeds {
+		host, seedPort, err := net.SplitHostPort(seed)
+		if err != nil {
+			host, seedPort = seed, port
+		}
+		addrs, err := SeedResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			EventLog().Warn("network: resolve seed", "seed", seed, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		for _, addr := range addrs {
+			address := net.JoinHostPort(addr.IP.String(), seedPort)
+			if !seen[address] {
+				seen[address] = true
+				res = append(res, address)
+			}
+		}
+	}
+	if len(res) == 0 {
+		if len(errs) == 0 {
+			return nil, ErrNoSeeds
+		}
+		return nil, fmt.Errorf("%w: %w", ErrNoSeeds, errors.Join(errs...))
+	}
+	rand.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] })
+	return res, nil
+}
+
+// AddSeeds adds the addresses seeds resolve to, see ResolveSeeds.
+func (pm *PeerManager) AddSeeds(ctx context.Context, seeds []string, port string) error {
+	list, err := ResolveSeeds(ctx, seeds, port)
+	if err != nil {
+		return err
+	}
+	for _, address := range list {
+		pm.Add(address)
+	}
+	return nil
+}
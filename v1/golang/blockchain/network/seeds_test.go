@@ -0,0 +1,148 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// useDNS points SeedResolver at an in-process DNS server answering A and AAAA
+// queries from records, keyed by hostname. Other names don't exist.
+func useDNS(t *testing.T, records map[string][]string) {
+	saved := SeedResolver
+	SeedResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveDNS(t, server, records)
+			return client, nil
+		},
+	}
+	t.Cleanup(func() { SeedResolver = saved })
+}
+
+// serveDNS answers the length-prefixed queries of a stream connection.
+func serveDNS(t *testing.T, conn net.Conn, records map[string][]string) {
+	defer conn.Close()
+	for {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+			t.Errorf("bad DNS query: %v", err)
+			return
+		}
+		q := msg.Questions[0]
+		msg.Header.Response = true
+		msg.Header.Authoritative = true
+		msg.Header.RecursionAvailable = true
+		ips, ok := records[strings.TrimSuffix(q.Name.String(), ".")]
+		if !ok {
+			msg.Header.RCode = dnsmessage.RCodeNameError
+		}
+		for _, s := range ips {
+			ip := net.ParseIP(s)
+			header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+			switch {
+			case q.Type == dnsmessage.TypeA && ip.To4() != nil:
+				var a dnsmessage.AResource
+				copy(a.A[:], ip.To4())
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &a})
+			case q.Type == dnsmessage.TypeAAAA && ip.To4() == nil:
+				var aaaa dnsmessage.AAAAResource
+				copy(aaaa.AAAA[:], ip)
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &aaaa})
+			}
+		}
+		res, err := msg.Pack()
+		if err != nil {
+			t.Errorf("packing DNS response: %v", err)
+			return
+		}
+		binary.BigEndian.PutUint16(size[:], uint16(len(res)))
+		if _, err := conn.Write(append(size[:], res...)); err != nil {
+			return
+		}
+	}
+}
+
+func TestResolveSeeds(t *testing.T) {
+	got, err := ResolveSeeds(context.Background(), []string{"127.0.0.1", "127.0.0.2:9000", "127.0.0.1"}, "8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"127.0.0.1:8080", "127.0.0.2:9000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveSeedsHostnames(t *testing.T) {
+	useDNS(t, map[string][]string{
+		"seed-a.test": {"10.0.0.1", "10.0.0.2", "2001:db8::1"},
+		"seed-b.test": {"10.0.0.2", "10.0.0.3"},
+	})
+	seeds := []string{"seed-a.test", "seed-b.test", "missing.test", "seed-b.test:9000"}
+	got, err := ResolveSeeds(context.Background(), seeds, "8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{
+		"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.2:9000", "10.0.0.3:8080", "10.0.0.3:9000", "[2001:db8::1]:8080",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	_, err = ResolveSeeds(context.Background(), []string{"missing.test"}, "8080")
+	var dnsErr *net.DNSError
+	if !errors.Is(err, ErrNoSeeds) || !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("unknown seed: got %v, want ErrNoSeeds wrapping a not found DNSError", err)
+	}
+}
+
+func TestResolveSeedsNone(t *testing.T) {
+	if _, err := ResolveSeeds(context.Background(), nil, "8080"); !errors.Is(err, ErrNoSeeds) {
+		t.Fatalf("no seeds: got %v, want ErrNoSeeds", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ResolveSeeds(ctx, []string{"seed.invalid"}, "8080")
+	if !errors.Is(err, ErrNoSeeds) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("failed lookup: got %v, want ErrNoSeeds wrapping the lookup error", err)
+	}
+}
+
+func TestAddSeeds(t *testing.T) {
+	pm := NewPeerManager()
+	if err := pm.AddSeeds(context.Background(), []string{"127.0.0.1"}, "8080"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pm.List(); !reflect.DeepEqual(got, []string{"127.0.0.1:8080"}) {
+		t.Fatalf("got %v", got)
+	}
+
+	useDNS(t, map[string][]string{"seed.test": {"10.0.0.1", "127.0.0.1"}})
+	if err := pm.AddSeeds(context.Background(), []string{"seed.test"}, "8080"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pm.List(), []string{"10.0.0.1:8080", "127.0.0.1:8080"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want the known peer merged with the seed: %v", got, want)
+	}
+}
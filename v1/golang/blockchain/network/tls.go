@@ -0,0 +1,62 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// ListenTLS address ip:port, connections are served over TLS configured by cfg.
+func ListenTLS(address string, cfg *tls.Config, handle func(Conn, *Package), opts ...ListenOption) (*Listener, error) {
+	listener, err := listenTCP(address)
+	if err != nil {
+		return nil, err
+	}
+	return newListener(context.Background(), tls.NewListener(listener, cfg), handle, opts)
+}
+
+// SendTLS package to address over TLS and wait WaitTime seconds for the response.
+// The connection is opened by DefaultDialer, so a proxy set there is used.
+func SendTLS(address string, cfg *tls.Config, pack *Package) (*Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+	defer cancel()
+	return send(ctx, sharedSendSlots(), tlsDialer{DefaultDialer, cfg}.DialContext, address, pack)
+}
+
+// WithTLS runs the connection over TLS configured by cfg, for listeners made by ListenTLS.
+func WithTLS(cfg *tls.Config) DialOption {
+	return func(c *Client) { c.tls = cfg }
+}
+
+// tlsDialer opens a connection with Dialer and runs the TLS handshake over it.
+type tlsDialer struct {
+	Dialer
+	cfg *tls.Config
+}
+
+func (d tlsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	cfg := d.cfg
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+		// verify the certificate against the host dialed, like tls.Dial does
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,113 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSigned makes a certificate for 127.0.0.1 in memory and returns the config
+// serving it and a client config trusting it.
+func selfSigned(t testing.TB) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "node"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: roots}
+}
+
+// listenTLS serves handle over TLS on a free local port until the test ends.
+func listenTLS(t testing.TB, cfg *tls.Config, handle func(Conn, *Package)) string {
+	t.Helper()
+	l, err := ListenTLS("127.0.0.1:0", cfg, handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+func TestSendTLS(t *testing.T) {
+	server, client := selfSigned(t)
+	address := listenTLS(t, server, echo)
+	for name, cfg := range map[string]*tls.Config{
+		"verified": client,
+		"insecure": {InsecureSkipVerify: true},
+	} {
+		res, err := SendTLS(address, cfg, &Package{Option: 1, Data: "over tls"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.Data != "over tls" {
+			t.Fatalf("%s: got %q", name, res.Data)
+		}
+	}
+}
+
+func TestSendTLSUntrusted(t *testing.T) {
+	server, _ := selfSigned(t)
+	address := listenTLS(t, server, echo)
+	if _, err := SendTLS(address, &tls.Config{}, &Package{Option: 1}); err == nil {
+		t.Fatal("SendTLS trusted a self-signed certificate")
+	}
+}
+
+func TestPlainClientToTLSListener(t *testing.T) {
+	server, _ := selfSigned(t)
+	address := listenTLS(t, server, echo)
+	start := time.Now()
+	res, err := Send(address, &Package{Option: 1})
+	if err == nil {
+		t.Fatalf("plain Send got %v", res)
+	}
+	if elapsed := time.Since(start); elapsed > WaitTime*time.Second/2 {
+		t.Fatalf("plain Send failed after %v, want a prompt failure", elapsed)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	server, client := selfSigned(t)
+	address := listenTLS(t, server, echo)
+	c, err := Dial(address, WithTLS(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, data := range []string{"one", "two"} {
+		if res, err := c.Send(&Package{Option: 1, Data: data}); err != nil || res.Data != data {
+			t.Fatalf("got %v, %v", res, err)
+		}
+	}
+	_, plain := listen(t, echo)
+	if _, err := SendTLS(plain, client, &Package{Option: 1}); err == nil {
+		t.Fatal("TLS client talked to a plain listener")
+	}
+}
@@ -0,0 +1,81 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// MaxDatagramSize keeps a framed package within one unfragmented UDP datagram.
+const MaxDatagramSize = 1200
+
+// maxUDPBackoff caps the wait after repeated read errors of a UDP listener.
+const maxUDPBackoff = time.Second
+
+var ErrDatagramTooLarge = errors.New("network: package exceeds MaxDatagramSize")
+
+// ListenUDP calls handle for every package received as a datagram on address.
+// Delivery is fire-and-forget, malformed and oversized datagrams are dropped. Read
+// errors are logged to EventLog and retried after a growing pause, up to a second.
+// Close the returned conn to stop listening.
+func ListenUDP(address string, handle func(addr *net.UDPAddr, pack *Package)) (*net.UDPConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", address, err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", address, err)
+	}
+	go serveUDP(conn, handle)
+	return conn, nil
+}
+
+func serveUDP(conn *net.UDPConn, handle func(*net.UDPAddr, *Package)) {
+	buffer := make([]byte, MaxDatagramSize+1)
+	var backoff time.Duration
+	for {
+		length, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// a persistent error would otherwise spin the loop
+			backoff = min(max(2*backoff, 5*time.Millisecond), maxUDPBackoff)
+			EventLog().Warn("network: udp read", "addr", conn.LocalAddr().String(), "err", err, "backoff", backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		if length > MaxDatagramSize {
+			continue
+		}
+		id, data, err := readFrame(bytes.NewReader(buffer[:length]), defaultConnConfig)
+		if err != nil || id != CodecJSON {
+			continue
+		}
+		pack := DeserializePackage(string(data))
+		if pack == nil {
+			continue
+		}
+		pack.RemoteAddr = addr.String()
+		handle(addr, pack)
+	}
+}
+
+// SendUDP sends pack to address as a single datagram without waiting for a response.
+func SendUDP(address string, pack *Package) error {
+	data := frame(CodecJSON, nil, []byte(SerializePackage(pack)))
+	if len(data) > MaxDatagramSize {
+		return fmt.Errorf("%w: %d bytes", ErrDatagramTooLarge, len(data))
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrDial, address, err)
+	}
+	defer conn.Close()
+	_, err = conn.Write(data)
+	return err
+}
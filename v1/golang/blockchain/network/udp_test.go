@@ -0,0 +1,88 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenUDP(t *testing.T) (*net.UDPConn, chan *Package) {
+	t.Helper()
+	received := make(chan *Package, 8)
+	conn, err := ListenUDP("127.0.0.1:0", func(addr *net.UDPAddr, pack *Package) { received <- pack })
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, received
+}
+
+func receive(t *testing.T, received chan *Package) *Package {
+	t.Helper()
+	select {
+	case pack := <-received:
+		return pack
+	case <-time.After(5 * time.Second):
+		t.Fatal("no datagram received")
+		return nil
+	}
+}
+
+func TestUDP(t *testing.T) {
+	conn, received := listenUDP(t)
+	if err := SendUDP(conn.LocalAddr().String(), &Package{Option: 1, Data: "tx"}); err != nil {
+		t.Fatal(err)
+	}
+	if pack := receive(t, received); pack.Data != "tx" || pack.RemoteAddr == "" {
+		t.Fatalf("got %+v", pack)
+	}
+}
+
+func TestSendUDPTooLarge(t *testing.T) {
+	conn, received := listenUDP(t)
+	err := SendUDP(conn.LocalAddr().String(), &Package{Option: 1, Data: strings.Repeat("x", MaxDatagramSize)})
+	if !errors.Is(err, ErrDatagramTooLarge) {
+		t.Fatalf("got %v, want ErrDatagramTooLarge", err)
+	}
+	// the largest package that fits still goes through
+	overhead := len(frame(CodecJSON, nil, []byte(SerializePackage(&Package{Option: 1, Data: "x"})))) - 1
+	data := strings.Repeat("x", MaxDatagramSize-overhead)
+	if err := SendUDP(conn.LocalAddr().String(), &Package{Option: 1, Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	if pack := receive(t, received); pack.Data != data {
+		t.Fatalf("got %d bytes, want %d", len(pack.Data), len(data))
+	}
+}
+
+func TestUDPReadErrorsBackOff(t *testing.T) {
+	events := captureEvents(t)
+	conn, received := listenUDP(t)
+	// every read fails until the deadline is cleared
+	conn.SetReadDeadline(time.Now().Add(-time.Second))
+	time.Sleep(200 * time.Millisecond)
+	conn.SetReadDeadline(time.Time{})
+
+	events.mu.Lock()
+	failures := len(events.events)
+	events.mu.Unlock()
+	if failures == 0 || failures > 20 {
+		t.Fatalf("%d read errors logged in 200ms, want a few", failures)
+	}
+	// the listener recovers once reads work again
+	for {
+		if err := SendUDP(conn.LocalAddr().String(), &Package{Option: 1, Data: "after"}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case pack := <-received:
+			if pack.Data != "after" {
+				t.Fatalf("got %+v", pack)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
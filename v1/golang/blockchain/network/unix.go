@@ -0,0 +1,55 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// UnixPrefix marks an address passed to Send or Dial as a unix socket path.
+const UnixPrefix = "unix://"
+
+// ListenUnix serves packages on the unix socket at path. A stale socket file
+// left by a crashed process is removed, the file is removed again on Close.
+func ListenUnix(path string, handle func(Conn, *Package), opts ...ListenOption) (*Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("network: listen %q: %w", path, err)
+	}
+	return newListener(context.Background(), listener, handle, opts)
+}
+
+// removeStaleSocket deletes path if it is a socket nobody listens on.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return syscall.EADDRINUSE
+	}
+	return os.Remove(path)
+}
+
+// splitAddress returns the network and address to dial, "unix" for UnixPrefix addresses.
+func splitAddress(address string) (string, string) {
+	if path, ok := strings.CutPrefix(address, UnixPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.sock")
+	l, err := ListenUnix(path, echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"first", "second"} {
+		res, err := Send(UnixPrefix+path, &Package{Option: 1, Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Data != data {
+			t.Fatalf("got %q, want %q", res.Data, data)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("socket file left after Close: %v", err)
+	}
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := ListenUnix(path, echo)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	defer l.Close()
+	if _, err := ListenUnix(path, echo); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("socket in use: got %v, want EADDRINUSE", err)
+	}
+}
+
+func TestListenUnixNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := ListenUnix(path, echo); err == nil {
+		l.Close()
+		t.Fatal("ListenUnix replaced a regular file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "data" {
+		t.Fatal("regular file changed")
+	}
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+var errBroken = errors.New("connection broke")
+
+// failingConn writes at most limit bytes, then fails. Each Write takes at most
+// step bytes without an error, like a socket taking part of the buffer.
+type failingConn struct {
+	net.Conn
+	limit, step int
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if c.limit <= 0 {
+		return 0, errBroken
+	}
+	n := min(len(b), c.limit, c.step)
+	c.limit -= n
+	return c.Conn.Write(b[:n])
+}
+
+func TestHandleWriteError(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	conn := NewConn(&failingConn{Conn: server, limit: 10, step: 4})
+	handled, err := Handle(1, conn, &Package{Option: 1}, func(*Package) string { return "response" })
+	if !handled || !errors.Is(err, errBroken) {
+		t.Fatalf("got %v, %v, want true, errBroken", handled, err)
+	}
+}
+
+func TestWriteFullPartialWrites(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	want := frame(CodecJSON, nil, []byte(SerializePackage(&Package{Option: 1, Data: "whole"})))
+	go func() {
+		writeFull(&failingConn{Conn: server, limit: len(want), step: 3}, want)
+		server.Close()
+	}()
+	got, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+type zeroWriter struct{ net.Conn }
+
+func (zeroWriter) Write([]byte) (int, error) { return 0, nil }
+
+func TestWriteFullNoProgress(t *testing.T) {
+	if err := writeFull(zeroWriter{}, []byte("x")); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("got %v, want io.ErrShortWrite", err)
+	}
+}
+
+func TestSendWriteError(t *testing.T) {
+	_, address := listen(t, echo)
+	useDialer(t, dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &failingConn{Conn: conn, limit: HeaderSize + 2, step: 1 << 20}, nil
+	}))
+	res, err := Send(address, &Package{Option: 1, Data: "never arrives whole"})
+	if !errors.Is(err, errBroken) || res != nil {
+		t.Fatalf("got %v, %v, want errBroken", res, err)
+	}
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// ListenWS serves packages to WebSocket clients connecting to address at path.
+// Each frame is carried in one binary WebSocket message, handlers and options
+// behave exactly as on the TCP listener.
+func ListenWS(address, path string, handle func(Conn, *Package), opts ...ListenOption) (*Listener, error) {
+	listener, err := listenTCP(address)
+	if err != nil {
+		return nil, err
+	}
+	return newListener(context.Background(), newWSListener(listener, path), handle, opts)
+}
+
+// SendWS package to the ws:// or wss:// url and wait WaitTime seconds for the response.
+func SendWS(rawURL string, pack *Package) (*Package, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitTime*time.Second)
+	defer cancel()
+	return send(ctx, sharedSendSlots(), dialWS, rawURL, pack)
+}
+
+func dialWS(ctx context.Context, _, rawURL string) (net.Conn, error) {
+	location, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	// websocket.Dial requires an Origin, use the http(s) root of the endpoint
+	origin := &url.URL{Scheme: strings.Replace(location.Scheme, "ws", "http", 1), Host: location.Host}
+	cfg, err := websocket.NewConfig(rawURL, origin.String())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}
+
+// wsListener turns upgraded WebSocket requests into connections returned by Accept.
+type wsListener struct {
+	net.Listener
+	server *http.Server
+	conns  chan net.Conn
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newWSListener(listener net.Listener, path string) *wsListener {
+	l := &wsListener{
+		Listener: listener,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, websocket.Handler(l.serveWS))
+	l.server = &http.Server{Handler: mux}
+	go l.server.Serve(listener)
+	return l
+}
+
+// serveWS hands the connection to Accept and keeps the request open until it is closed.
+func (l *wsListener) serveWS(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	conn := &wsConn{Conn: ws, closed: make(chan struct{})}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		return
+	}
+	<-conn.closed
+}
+
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *wsListener) Close() error {
+	err := net.ErrClosed
+	l.once.Do(func() {
+		close(l.done)
+		err = l.server.Close()
+	})
+	return err
+}
+
+// wsConn signals serveWS when the Listener is done with the connection.
+type wsConn struct {
+	*websocket.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *wsConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	err := c.Conn.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
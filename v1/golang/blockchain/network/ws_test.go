@@ -0,0 +1,73 @@
+package network
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	toUpper Option = iota + 1
+	toLower
+)
+
+// listenWS serves the ToUpper and ToLower options of the mainnet example over
+// WebSocket and returns the url of the endpoint.
+func listenWS(t *testing.T) string {
+	t.Helper()
+	mux := NewMux()
+	mux.HandleFunc(toUpper, func(_ context.Context, pack *Package) (string, error) {
+		return strings.ToUpper(pack.Data), nil
+	})
+	mux.HandleFunc(toLower, func(_ context.Context, pack *Package) (string, error) {
+		return strings.ToLower(pack.Data), nil
+	})
+	l, err := ListenWS("127.0.0.1:0", "/ws", mux.ServeConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return "ws://" + l.Addr().String() + "/ws"
+}
+
+func TestSendWS(t *testing.T) {
+	url := listenWS(t)
+	for option, want := range map[Option]string{toUpper: "HELLO, WORLD!", toLower: "hello, world!"} {
+		res, err := SendWS(url, &Package{Option: option, Data: "Hello, World!"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Data != want {
+			t.Fatalf("option %d: got %q, want %q", option, res.Data, want)
+		}
+	}
+}
+
+func TestWSPersistentConn(t *testing.T) {
+	url := listenWS(t)
+	ws, err := websocket.Dial(url, "", "http://"+strings.TrimPrefix(url, "ws://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	conn := NewConn(ws)
+	defer conn.Close()
+	for _, option := range []Option{toUpper, toLower, toUpper} {
+		if err := conn.WritePackage(&Package{Option: option, Data: "MiXeD"}); err != nil {
+			t.Fatal(err)
+		}
+		res, err := conn.ReadPackage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.ToLower("MiXeD")
+		if option == toUpper {
+			want = strings.ToUpper("MiXeD")
+		}
+		if res.Data != want {
+			t.Fatalf("option %d: got %q, want %q", option, res.Data, want)
+		}
+	}
+}